@@ -2,55 +2,192 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"html/template"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"regexp"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
+	"airprint-service/ipp"
+
 	"github.com/grandcat/zeroconf"
 )
 
+// PrintDocument 打印任务中的单个文档，数据保存在暂存目录中
+type PrintDocument struct {
+	Number   int    `json:"number"`
+	Name     string `json:"name,omitempty"`
+	Format   string `json:"format"`             // 客户端指定的 document-format
+	Detected string `json:"detected,omitempty"` // 按文档内容识别的格式（document-format-detected）
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+}
+
+// EffectiveFormat 返回处理文档时使用的格式
+//
+// 客户端明确指定格式时以其为准，指定 application/octet-stream 或未指定时使用识别出的格式。
+func (d *PrintDocument) EffectiveFormat() string {
+	if d.Format == formatOctetStream && d.Detected != "" {
+		return d.Detected
+	}
+	return d.Format
+}
+
 // PrintJob 打印任务
 type PrintJob struct {
 	ID           int
 	Name         string
-	Format       string
-	Data         []byte
-	Status       string
+	User         string
+	Documents    []*PrintDocument
+	HoldUntil    string
+	Template     JobTemplate
+	Incoming     bool // Create-Job 创建后仍在等待最后一个文档
+	State        ipp.JobState
+	Reasons      []string // job-state-reasons
+	Error        string   // 任务中止、等待重试或改用备用打印机的原因（job-state-message）
 	CreatedAt    time.Time
+	ProcessingAt time.Time
+	CompletedAt  time.Time
 	PrinterName  string
+	RemoteJobID  string // 系统打印队列中的任务 ID（如 CUPS 的 "Printer-42"）
+
+	cancel context.CancelFunc
+}
+
+// DocumentFormat 返回任务第一个文档的格式
+func (j *PrintJob) DocumentFormat() string {
+	if len(j.Documents) == 0 {
+		return "application/octet-stream"
+	}
+	return j.Documents[0].Format
+}
+
+// DocumentFormatDetected 返回任务第一个文档按内容识别的格式，无法识别时返回空字符串
+func (j *PrintJob) DocumentFormatDetected() string {
+	if len(j.Documents) == 0 {
+		return ""
+	}
+	return j.Documents[0].Detected
+}
+
+// Size 返回任务全部文档的总字节数
+func (j *PrintJob) Size() int64 {
+	var size int64
+	for _, doc := range j.Documents {
+		size += doc.Size
+	}
+	return size
 }
 
 // AirPrintServer AirPrint 服务器
 type AirPrintServer struct {
 	printerManager PrinterManager
-	server         *zeroconf.Server
-	universalServer *zeroconf.Server
+	backends       *BackendManager
+	classes        *PrinterClassManager
+	printers       map[string]*sharedPrinter
+	defaultPrinter string
+	printersMu     sync.Mutex
 	httpServer     *http.Server
 	port           int
-	jobCounter     int
-	jobs           map[int]*PrintJob
+	localIP        net.IP // 启动时确定的本机地址，用于打印机与任务 URI 及 mDNS 注册
+	config         Config
+	spool          *Spool
+	state          *State
+	jobs           *JobStore
+	queues         map[string]*PrintQueue
+	queuesMu       sync.Mutex
+	stopRetention  context.CancelFunc
 }
 
 // NewAirPrintServer 创建新的 AirPrint 服务器
-func NewAirPrintServer(printerManager PrinterManager) *AirPrintServer {
+//
+// printerManager 为系统打印机管理器，配置文件中的网络打印机与打印机类在其之上加入。
+func NewAirPrintServer(printerManager PrinterManager, config Config) *AirPrintServer {
+	backends := NewBackendManager(printerManager, config)
+	classes := NewPrinterClassManager(backends, config.Classes)
 	return &AirPrintServer{
-		printerManager: printerManager,
-		port:           8082, // 默认端口
-		jobCounter:     0,
-		jobs:           make(map[int]*PrintJob),
+		printerManager: classes,
+		backends:       backends,
+		classes:        classes,
+		port:           config.Port,
+		config:         config,
+		printers:       make(map[string]*sharedPrinter),
+		jobs:           NewJobStore(),
+		queues:         make(map[string]*PrintQueue),
 	}
 }
 
+// PrinterManager 返回包含网络打印机与打印机类的打印机管理器
+func (a *AirPrintServer) PrinterManager() PrinterManager {
+	return a.printerManager
+}
+
+// Jobs 返回任务存储，GUI 与网页可以订阅其中的任务变化
+func (a *AirPrintServer) Jobs() *JobStore {
+	return a.jobs
+}
+
+// Port 返回服务监听端口
+func (a *AirPrintServer) Port() int {
+	return a.port
+}
+
 // Start 启动 AirPrint 服务
 func (a *AirPrintServer) Start() error {
+	// 本机地址在运行期间不变，只在启动时查询一次
+	localIP, err := getLocalIP()
+	if err != nil {
+		return fmt.Errorf("获取本机 IP 失败: %v", err)
+	}
+	a.localIP = localIP
+
+	// 准备文档暂存目录
+	spool, err := NewSpool(a.config.SpoolDir)
+	if err != nil {
+		return fmt.Errorf("创建暂存目录失败: %v", err)
+	}
+	a.spool = spool
+
+	// 读取打印机 UUID 等持久化状态；文件损坏时由 LoadState 改名保留并重新生成，
+	// 无法读取时不启动服务，以免覆盖原有的打印机 UUID
+	state, err := LoadState(a.config.StatePath())
+	if err != nil {
+		return fmt.Errorf("读取服务状态失败: %v", err)
+	}
+	// 状态无法保存时打印机 UUID 不能跨重启保持，不启动服务
+	if err := state.Save(); err != nil {
+		return fmt.Errorf("保存服务状态失败: %v", err)
+	}
+	a.state = state
+
+	// 恢复任务历史，服务停止时未完成的任务重新排队
+	requeue, err := a.jobs.Open(a.config.JobHistoryPath())
+	if err != nil {
+		log.Printf("打开任务历史失败，本次运行的任务不会保存: %v", err)
+	}
+
+	// 每台本地打印机对应一个 IPP 资源
+	a.loadSharedPrinters()
+
+	for _, job := range requeue {
+		log.Printf("重新排队未完成的打印任务 ID: %d", job.ID)
+		a.requeueJob(job)
+	}
+
+	// 按保留策略释放文档数据、清理任务记录
+	ctx, cancel := context.WithCancel(context.Background())
+	a.stopRetention = cancel
+	go a.runRetention(ctx)
+
 	// 启动 HTTP 服务器
 	if err := a.startHTTPServer(); err != nil {
 		return fmt.Errorf("启动 HTTP 服务器失败: %v", err)
@@ -67,16 +204,23 @@ func (a *AirPrintServer) Start() error {
 
 // Stop 停止 AirPrint 服务
 func (a *AirPrintServer) Stop() error {
-	// 停止 mDNS 服务
-	if a.server != nil {
-		a.server.Shutdown()
-		a.server = nil
+	// 停止各打印机的 mDNS 服务
+	for _, printer := range a.sharedPrinterList() {
+		if printer.server != nil {
+			printer.server.Shutdown()
+			printer.server = nil
+		}
+
+		// 停止 universal subtype 服务
+		if printer.universalServer != nil {
+			printer.universalServer.Shutdown()
+			printer.universalServer = nil
+		}
 	}
-	
-	// 停止 universal subtype 服务
-	if a.universalServer != nil {
-		a.universalServer.Shutdown()
-		a.universalServer = nil
+
+	if a.stopRetention != nil {
+		a.stopRetention()
+		a.stopRetention = nil
 	}
 
 	// 停止 HTTP 服务器
@@ -93,7 +237,7 @@ func (a *AirPrintServer) Stop() error {
 func (a *AirPrintServer) startHTTPServer() error {
 	mux := http.NewServeMux()
 	
-	// IPP 端点
+	// IPP 端点：/ipp/print 为默认打印机，/ipp/print/<队列名> 为各打印机
 	mux.HandleFunc("/ipp/print", a.handleIPPRequest)
 	mux.HandleFunc("/ipp/", a.handleIPPRequest)
 	
@@ -115,74 +259,77 @@ func (a *AirPrintServer) startHTTPServer() error {
 	return nil
 }
 
-// registerMDNSService 注册 mDNS 服务发现
+// registerMDNSService 为每台共享打印机注册 mDNS 服务发现
 func (a *AirPrintServer) registerMDNSService() error {
-	// 获取本机 IP 地址
-	localIP, err := getLocalIP()
-	if err != nil {
-		return fmt.Errorf("获取本机 IP 失败: %v", err)
+	registered := 0
+	for _, printer := range a.sharedPrinterList() {
+		if err := a.registerPrinterMDNS(printer, a.localIP); err != nil {
+			log.Printf("打印机 %s 的 mDNS 服务注册失败: %v", printer.displayName(), err)
+			continue
+		}
+		registered++
+	}
+	if registered == 0 {
+		return fmt.Errorf("没有任何打印机注册成功")
 	}
+	return nil
+}
 
-	// 获取默认打印机信息
-	defaultPrinter, err := a.printerManager.GetDefault()
-	if err != nil {
-		defaultPrinter = "AirPrint Service"
+// registerPrinterMDNS 注册单台打印机的 _ipp._tcp 服务及 universal subtype
+func (a *AirPrintServer) registerPrinterMDNS(printer *sharedPrinter, localIP net.IP) error {
+	displayName := printer.displayName()
+	model := printer.Caps.MakeAndModel
+	if model == "" {
+		model = displayName
 	}
 
 	// 服务名称 - iOS 需要特定格式
 	hostname, _ := os.Hostname()
-	serviceName := fmt.Sprintf("%s @ %s", defaultPrinter, hostname)
+	serviceName := fmt.Sprintf("%s @ %s", displayName, hostname)
 
 	// mDNS 服务属性 - iOS AirPrint 兼容格式
 	txtRecords := []string{
 		"txtvers=1",
 		"qtotal=1",
-		"rp=ipp/print",
-		"ty=" + defaultPrinter,
+		"rp=" + strings.TrimPrefix(printerResource(printer.Name), "/"),
+		"ty=" + displayName,
 		"adminurl=http://" + localIP.String() + ":" + fmt.Sprintf("%d", a.port) + "/",
-		"note=",
+		"note=" + printer.Info.Location,
 		"priority=0",
-		"product=(" + defaultPrinter + ")",
+		"product=(" + model + ")",
 		"printer-state=3",
-		"printer-type=0x809046",
-		// 关键：iOS 设备需要这些特定的格式支持
-		"pdl=application/octet-stream,application/pdf,application/postscript,image/urf,image/jpeg,image/png",
-		"URF=W8,SRGB24,CP1,RS300-600,V1.4,DM1",
-		"UUID=9c85edb1-1234-5678-9abc-123456789abc",
-		"Color=T",
-		"Duplex=F",
+		"UUID=" + printer.UUID,
 		"Staple=F",
 		"Sort=T",
 		"Collate=T",
 		"Punch=F",
 		"Copies=T",
 		"Bind=F",
-		"PaperMax=legal-A4",
 		"Kind=document,photo",
 		"PaperCustom=T",
 		// iOS 特定属性
 		"air=username,password",
 		"mopria-certified=1.3",
-		"printer-location=",
-		"printer-make-and-model=" + defaultPrinter,
+		"printer-location=" + printer.Info.Location,
+		"printer-make-and-model=" + model,
 	}
+	// pdl、URF、Color、Duplex、PaperMax、printer-type 由打印机能力生成，与 IPP 属性一致
+	txtRecords = append(txtRecords, printer.Caps.txtRecords()...)
 
 	// 注册主要的 IPP 服务
 	server, err := zeroconf.Register(serviceName, "_ipp._tcp", "local.", a.port, txtRecords, nil)
 	if err != nil {
 		return fmt.Errorf("注册 IPP 服务失败: %v", err)
 	}
-	a.server = server
-	
+	printer.server = server
+
 	// 尝试注册 universal subtype（iOS AirPrint 发现需要）
 	// 注意：某些 mDNS 库可能不支持 subtype，这是正常的
 	universalServer, err := zeroconf.Register(serviceName, "_universal._sub._ipp._tcp", "local.", a.port, txtRecords, nil)
 	if err != nil {
 		log.Printf("注意：无法注册 universal subtype（这在某些系统上是正常的）: %v", err)
-		// 尝试替代方案：添加额外的 TXT 记录
-		log.Printf("使用备用方案进行 iOS 兼容性设置")
 	} else {
-		a.universalServer = universalServer
+		printer.universalServer = universalServer
 		log.Printf("已注册 universal subtype 服务")
 	}
 
@@ -217,9 +364,28 @@ func (a *AirPrintServer) handleRootRequest(w http.ResponseWriter, r *http.Reques
 		if printer.IsDefault {
 			status += " (默认)"
 		}
-		html += fmt.Sprintf("        <li>%s - %s</li>\n", printer.Name, status)
+		html += fmt.Sprintf("        <li>%s - %s - %s</li>\n",
+			template.HTMLEscapeString(printer.Name), status,
+			template.HTMLEscapeString(a.printerURI(printer.Name)))
 	}
 	
+	html += `    </ul>
+    <h2>打印任务:</h2>
+    <ul>
+`
+
+	// 最新的任务在前
+	jobs := a.jobs.List()
+	a.jobs.View(func() {
+		for i := len(jobs) - 1; i >= 0; i-- {
+			job := jobs[i]
+			html += fmt.Sprintf("        <li>#%d %s - %s - %s (%s)</li>\n",
+				job.ID, template.HTMLEscapeString(job.Name),
+				template.HTMLEscapeString(job.PrinterName), job.State,
+				strings.Join(job.Reasons, ", "))
+		}
+	})
+
 	html += `    </ul>
     <p>服务正在运行，可以通过 AirPrint 进行打印。</p>
 </body>
@@ -239,377 +405,901 @@ func (a *AirPrintServer) handleIPPRequest(w http.ResponseWriter, r *http.Request
 	w.Header().Set("Content-Type", "application/ipp")
 	w.Header().Set("Server", "AirPrint/1.0")
 	
+	// 根据请求路径确定目标打印机
+	printer := a.printerForPath(r.URL.Path)
+	if printer == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
 	switch r.Method {
 	case "POST":
 		// 处理 IPP POST 请求（打印任务、获取属性等）
-		a.handleIPPPost(w, r)
+		a.handleIPPPost(w, r, printer)
 	case "GET":
 		// 处理 IPP GET 请求
-		a.handleIPPGet(w, r)
+		a.handleIPPGet(w, r, printer)
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 // handleIPPPost 处理 IPP POST 请求
-func (a *AirPrintServer) handleIPPPost(w http.ResponseWriter, r *http.Request) {
+func (a *AirPrintServer) handleIPPPost(w http.ResponseWriter, r *http.Request, printer *sharedPrinter) {
 	log.Printf("处理 IPP POST 请求")
-	
-	// 读取 IPP 请求体
-	body := make([]byte, r.ContentLength)
-	n, err := r.Body.Read(body)
-	if err != nil && n == 0 {
-		http.Error(w, "Failed to read request body", http.StatusBadRequest)
-		return
-	}
-	body = body[:n] // 调整到实际读取的长度
-	
-	log.Printf("接收到 %d 字节的 IPP 数据", n)
-	
-	if len(body) < 8 {
-		log.Printf("IPP 请求太短: %d 字节", len(body))
+
+	// 流式解析 IPP 消息头与属性，读取在 end-of-attributes-tag 处停止，
+	// r.Body 中剩余的数据即为文档内容
+	req, err := ipp.NewDecoder(r.Body).Decode()
+	if err != nil {
+		log.Printf("解析 IPP 请求失败: %v", err)
 		http.Error(w, "Invalid IPP request", http.StatusBadRequest)
 		return
 	}
-	
-	// 解析 IPP 请求头
-	version := (uint16(body[0]) << 8) | uint16(body[1])
-	operation := (uint16(body[2]) << 8) | uint16(body[3])
-	requestID := (uint32(body[4]) << 24) | (uint32(body[5]) << 16) | (uint32(body[6]) << 8) | uint32(body[7])
-	
-	log.Printf("IPP 请求 - Version: 0x%04x, Operation: 0x%04x, RequestID: %d", version, operation, requestID)
-	
-	var response []byte
-	
-	switch operation {
-	case 0x000B: // Get-Printer-Attributes
-		response = a.buildGetPrinterAttributesResponse(requestID)
-	case 0x0002: // Print-Job
-		response = a.buildPrintJobResponse(requestID, body)
-	case 0x0004: // Validate-Job
-		response = a.buildValidateJobResponse(requestID)
+
+	log.Printf("IPP 请求 - Version: %s, Operation: %s, RequestID: %d", req.Version, req.Op(), req.RequestID)
+
+	var response *ipp.Message
+
+	switch {
+	case req.Version.Major() != 1 && req.Version.Major() != 2:
+		response = a.buildErrorResponse(req, ipp.StatusErrorVersionNotSupported)
+	case req.Operation() == nil:
+		response = a.buildErrorResponse(req, ipp.StatusErrorBadRequest)
+	case operatorOps[req.Op()] && !a.operatorAllowed(r, req):
+		log.Printf("拒绝来自 %s 的操作员操作 %s", r.RemoteAddr, req.Op())
+		response = a.buildErrorResponse(req, ipp.StatusErrorNotAuthorized)
 	default:
-		response = a.buildErrorResponse(requestID, 0x0500) // server-error-operation-not-supported
+		switch req.Op() {
+		case ipp.OpGetPrinterAttributes:
+			response = a.buildGetPrinterAttributesResponse(req, printer)
+		case ipp.OpPrintJob:
+			response = a.buildPrintJobResponse(req, printer, r.Body)
+		case ipp.OpCreateJob:
+			response = a.buildCreateJobResponse(req, printer)
+		case ipp.OpSendDocument:
+			response = a.buildSendDocumentResponse(req, printer, r.Body)
+		case ipp.OpCloseJob:
+			response = a.buildCloseJobResponse(req, printer)
+		case ipp.OpValidateJob:
+			response = a.buildValidateJobResponse(req, printer)
+		case ipp.OpGetJobs:
+			response = a.buildGetJobsResponse(req, printer)
+		case ipp.OpGetJobAttributes:
+			response = a.buildGetJobAttributesResponse(req, printer)
+		case ipp.OpCancelJob:
+			response = a.buildCancelJobResponse(r, req, printer)
+		case ipp.OpHoldJob:
+			response = a.buildHoldJobResponse(r, req, printer)
+		case ipp.OpReleaseJob:
+			response = a.buildReleaseJobResponse(r, req, printer)
+		case ipp.OpRestartJob:
+			response = a.buildRestartJobResponse(r, req, printer)
+		case ipp.OpPurgeJobs:
+			response = a.buildPurgeJobsResponse(req, printer)
+		case ipp.OpPausePrinter:
+			response = a.buildPausePrinterResponse(req, printer)
+		case ipp.OpResumePrinter:
+			response = a.buildResumePrinterResponse(req, printer)
+		default:
+			response = a.buildErrorResponse(req, ipp.StatusErrorOperationNotSupported)
+		}
 	}
-	
+
+	data, err := response.Marshal()
+	if err != nil {
+		log.Printf("编码 IPP 响应失败: %v", err)
+		http.Error(w, "Failed to encode IPP response", http.StatusInternalServerError)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
-	w.Write(response)
+	w.Write(data)
 }
 
 // handleIPPGet 处理 IPP GET 请求
-func (a *AirPrintServer) handleIPPGet(w http.ResponseWriter, r *http.Request) {
+func (a *AirPrintServer) handleIPPGet(w http.ResponseWriter, r *http.Request, printer *sharedPrinter) {
 	log.Printf("处理 IPP GET 请求: %s", r.URL.Path)
-	
+
 	// 对于 IPP 端点的 GET 请求，返回打印机信息
-	if r.URL.Path == "/ipp/print" || r.URL.Path == "/ipp/" {
-		w.Header().Set("Content-Type", "text/plain")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("AirPrint Service Ready - Use POST for IPP operations"))
-		return
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf("AirPrint Service Ready (%s) - Use POST for IPP operations", printer.displayName())))
+}
+
+// operatorOps 只允许操作员执行的操作
+var operatorOps = map[ipp.Op]bool{
+	ipp.OpPurgeJobs:     true,
+	ipp.OpPausePrinter:  true,
+	ipp.OpResumePrinter: true,
+}
+
+// operatorAllowed 判断请求能否执行操作员操作：来自本机（回环地址）的请求，
+// 或 requesting-user-name 属于配置的管理员
+//
+// requesting-user-name 由客户端提供且未经认证，只应在可信的网络中配置管理员。
+func (a *AirPrintServer) operatorAllowed(r *http.Request, req *ipp.Message) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
 	}
-	
-	// 其他 GET 请求
-	http.Error(w, "Not Found", http.StatusNotFound)
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+
+	if attr, ok := req.Operation().Get("requesting-user-name"); ok {
+		if user, ok := attr.Str(); ok && user != "" {
+			return containsString(a.config.AdminUsers, user)
+		}
+	}
+	return false
+}
+
+// newResponse 创建与请求版本、请求 ID 对应的响应消息
+func (a *AirPrintServer) newResponse(req *ipp.Message, status ipp.Status) *ipp.Message {
+	version := req.Version
+	if version.Major() != 1 && version.Major() != 2 {
+		version = ipp.Version11
+	}
+	return ipp.NewResponse(version, status, req.RequestID)
+}
+
+// printerURI 返回打印队列的 IPP URI，主机为启动时确定的本机地址
+func (a *AirPrintServer) printerURI(name string) string {
+	return fmt.Sprintf("ipp://%s:%d%s", a.localIP.String(), a.port, printerResource(name))
 }
 
 // buildGetPrinterAttributesResponse 构建获取打印机属性响应
-func (a *AirPrintServer) buildGetPrinterAttributesResponse(requestID uint32) []byte {
-	// 获取本机 IP
-	localIP, _ := getLocalIP()
-	printerURI := fmt.Sprintf("ipp://%s:%d/ipp/print", localIP.String(), a.port)
-	
-	// 构建基本的 IPP 响应头
-	response := []byte{
-		0x01, 0x01, // IPP version 1.1
-		0x00, 0x00, // successful-ok
-		byte(requestID >> 24), byte(requestID >> 16), byte(requestID >> 8), byte(requestID), // request-id
-		0x01, // operation-attributes-tag
+func (a *AirPrintServer) buildGetPrinterAttributesResponse(req *ipp.Message, printer *sharedPrinter) *ipp.Message {
+	// 队列中有任务执行时打印机处于 processing 状态，队列停止时为 stopped
+	queue := a.queueFor(printer.Name)
+	waiting, running := queue.Len()
+	state := ipp.PrinterIdle
+	stateReason := "none"
+	if running > 0 {
+		state = ipp.PrinterProcessing
 	}
-	
-	// 添加 charset 属性
-	response = append(response, 0x47, 0x00, 0x12) // textWithoutLanguage tag + name length
-	response = append(response, []byte("attributes-charset")...)
-	response = append(response, 0x00, 0x05) // value length
-	response = append(response, []byte("utf-8")...)
-	
-	// 添加 natural-language 属性
-	response = append(response, 0x48, 0x00, 0x1B) // naturalLanguage tag + name length
-	response = append(response, []byte("attributes-natural-language")...)
-	response = append(response, 0x00, 0x05) // value length
-	response = append(response, []byte("en-us")...)
-	
-	// 打印机属性标签
-	response = append(response, 0x02) // printer-attributes-tag
-	
-	// printer-uri-supported
-	response = append(response, 0x45, 0x00, 0x13) // uri tag + name length
-	response = append(response, []byte("printer-uri-supported")...)
-	response = append(response, byte(len(printerURI)>>8), byte(len(printerURI))) // value length
-	response = append(response, []byte(printerURI)...)
-	
-	// printer-name
-	printerName := "AirPrint Service"
-	response = append(response, 0x42, 0x00, 0x0C) // nameWithoutLanguage tag + name length
-	response = append(response, []byte("printer-name")...)
-	response = append(response, byte(len(printerName)>>8), byte(len(printerName))) // value length
-	response = append(response, []byte(printerName)...)
-	
-	// printer-state (3 = idle)
-	response = append(response, 0x21, 0x00, 0x0D) // enum tag + name length
-	response = append(response, []byte("printer-state")...)
-	response = append(response, 0x00, 0x04, 0x00, 0x00, 0x00, 0x03) // idle state
-	
-	// printer-state-reasons
-	response = append(response, 0x44, 0x00, 0x15) // keyword tag + name length
-	response = append(response, []byte("printer-state-reasons")...)
-	response = append(response, 0x00, 0x04) // value length
-	response = append(response, []byte("none")...)
-	
-	// operations-supported
-	response = append(response, 0x21, 0x00, 0x13) // enum tag + name length
-	response = append(response, []byte("operations-supported")...)
-	response = append(response, 0x00, 0x04, 0x00, 0x00, 0x00, 0x02) // Print-Job
-	response = append(response, 0x21, 0x00, 0x00) // enum tag + no name (additional value)
-	response = append(response, 0x00, 0x04, 0x00, 0x00, 0x00, 0x04) // Validate-Job
-	response = append(response, 0x21, 0x00, 0x00) // enum tag + no name (additional value)
-	response = append(response, 0x00, 0x04, 0x00, 0x00, 0x00, 0x0B) // Get-Printer-Attributes
-	
-	// document-format-supported
-	response = append(response, 0x49, 0x00, 0x17) // mimeMediaType tag + name length
-	response = append(response, []byte("document-format-supported")...)
-	response = append(response, 0x00, 0x0F) // value length
-	response = append(response, []byte("application/pdf")...)
-	response = append(response, 0x49, 0x00, 0x00) // mimeMediaType tag + no name (additional value)
-	response = append(response, 0x00, 0x0A) // value length
-	response = append(response, []byte("image/jpeg")...)
-	response = append(response, 0x49, 0x00, 0x00) // mimeMediaType tag + no name (additional value)
-	response = append(response, 0x00, 0x09) // value length
-	response = append(response, []byte("image/png")...)
-	
-	// color-supported
-	response = append(response, 0x22, 0x00, 0x0F) // boolean tag + name length
-	response = append(response, []byte("color-supported")...)
-	response = append(response, 0x00, 0x01, 0x01) // true
-	
-	// printer-resolution-supported
-	response = append(response, 0x32, 0x00, 0x1A) // resolution tag + name length
-	response = append(response, []byte("printer-resolution-supported")...)
-	response = append(response, 0x00, 0x09) // value length
-	response = append(response, 0x00, 0x00, 0x01, 0x2C, 0x00, 0x00, 0x01, 0x2C, 0x03) // 300x300 dpi
-	
-	// end-of-attributes-tag
-	response = append(response, 0x03)
-	
+	if reason := queue.Stopped(); reason != "" {
+		state = ipp.PrinterStopped
+		stateReason = reason
+	}
+
+	attrs := []ipp.Attribute{
+		ipp.StringAttr("printer-uri-supported", ipp.TagURI, a.printerURI(printer.Name)),
+		ipp.StringAttr("printer-name", ipp.TagName, printer.displayName()),
+		ipp.StringAttr("printer-info", ipp.TagText, printer.displayName()),
+		ipp.StringAttr("printer-location", ipp.TagText, printer.Info.Location),
+		ipp.StringAttr("printer-uuid", ipp.TagURI, "urn:uuid:"+printer.UUID),
+		ipp.IntAttr("printer-state", ipp.TagEnum, int(state)),
+		ipp.StringAttr("printer-state-reasons", ipp.TagKeyword, stateReason),
+		ipp.BoolAttr("printer-is-accepting-jobs", !queue.Full()),
+		ipp.IntAttr("queued-job-count", ipp.TagInteger, waiting+running),
+		ipp.IntAttr("operations-supported", ipp.TagEnum,
+			int(ipp.OpPrintJob),
+			int(ipp.OpValidateJob),
+			int(ipp.OpCreateJob),
+			int(ipp.OpSendDocument),
+			int(ipp.OpCancelJob),
+			int(ipp.OpGetJobAttributes),
+			int(ipp.OpGetJobs),
+			int(ipp.OpGetPrinterAttributes),
+			int(ipp.OpHoldJob),
+			int(ipp.OpReleaseJob),
+			int(ipp.OpRestartJob),
+			int(ipp.OpPausePrinter),
+			int(ipp.OpResumePrinter),
+			int(ipp.OpPurgeJobs),
+			int(ipp.OpCloseJob),
+		),
+		ipp.BoolAttr("multiple-document-jobs-supported", true),
+		ipp.StringAttr("job-hold-until-supported", ipp.TagKeyword, "no-hold", "indefinite"),
+		ipp.StringAttr("job-hold-until-default", ipp.TagKeyword, "no-hold"),
+	}
+	// 颜色、分辨率、文档格式、纸张等由打印机能力生成，与 mDNS TXT 记录一致
+	attrs = append(attrs, printer.Caps.printerAttributes()...)
+
+	response := a.newResponse(req, ipp.StatusOK)
+	response.AddGroup(ipp.TagPrinterGroup).Add(filterRequestedAttributes(req, attrs, nil)...)
 	return response
 }
 
+// buildPausePrinterResponse 构建 Pause-Printer 响应，停止打印机队列，正在执行的任务不受影响
+func (a *AirPrintServer) buildPausePrinterResponse(req *ipp.Message, printer *sharedPrinter) *ipp.Message {
+	a.queueFor(printer.Name).Stop("paused")
+	log.Printf("打印机 %s 的队列已暂停", printer.displayName())
+	return a.newResponse(req, ipp.StatusOK)
+}
+
+// buildResumePrinterResponse 构建 Resume-Printer 响应，恢复因暂停或打印失败而停止的队列
+func (a *AirPrintServer) buildResumePrinterResponse(req *ipp.Message, printer *sharedPrinter) *ipp.Message {
+	a.queueFor(printer.Name).Resume()
+	log.Printf("打印机 %s 的队列已恢复", printer.displayName())
+	return a.newResponse(req, ipp.StatusOK)
+}
+
+// filterRequestedAttributes 按 requested-attributes 过滤属性
+//
+// 请求未指定 requested-attributes 时使用 defaults，defaults 为 nil 表示返回全部；
+// 包含 "all" 时返回全部。
+func filterRequestedAttributes(req *ipp.Message, attrs []ipp.Attribute, defaults []string) []ipp.Attribute {
+	names := defaults
+	if requested, ok := req.Operation().Get("requested-attributes"); ok {
+		names = requested.Strings()
+	}
+	if names == nil {
+		return attrs
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		if name == "all" {
+			return attrs
+		}
+		wanted[name] = true
+	}
+
+	var filtered []ipp.Attribute
+	for _, attr := range attrs {
+		if wanted[attr.Name] {
+			filtered = append(filtered, attr)
+		}
+	}
+	return filtered
+}
+
 // buildPrintJobResponse 构建打印任务响应并实际执行打印
-func (a *AirPrintServer) buildPrintJobResponse(requestID uint32, requestBody []byte) []byte {
+func (a *AirPrintServer) buildPrintJobResponse(req *ipp.Message, printer *sharedPrinter, body io.Reader) *ipp.Message {
 	// 解析 IPP 请求以提取文档数据和属性
-	jobName, documentFormat, documentData := a.parseIPPPrintRequest(requestBody)
-	
-	// 创建打印任务
-	a.jobCounter++
-	jobID := a.jobCounter
-	
-	job := &PrintJob{
-		ID:          jobID,
-		Name:        jobName,
-		Format:      documentFormat,
-		Data:        documentData,
-		Status:      "pending",
-		CreatedAt:   time.Now(),
-		PrinterName: "",
+	job, unsupported := a.parseJobRequest(req, printer)
+	if rejected := a.rejectUnsupported(req, unsupported); rejected != nil {
+		return rejected
 	}
-	
-	// 获取默认打印机
-	if defaultPrinter, err := a.printerManager.GetDefault(); err == nil {
-		job.PrinterName = defaultPrinter
+	// 队列已满时在读取文档之前拒绝
+	if a.queueFor(printer.Name).Full() {
+		return a.buildErrorResponse(req, ipp.StatusErrorBusy)
 	}
-	
-	a.jobs[jobID] = job
-	
-	log.Printf("创建打印任务 - ID: %d, 名称: %s, 格式: %s, 大小: %d 字节", 
-		jobID, jobName, documentFormat, len(documentData))
-	
-	// 异步执行实际打印
-	go a.executePrintJob(job)
-	
-	// 构建 IPP 响应
-	response := []byte{
-		0x01, 0x01, // IPP version 1.1
-		0x00, 0x00, // successful-ok
-		byte(requestID >> 24), byte(requestID >> 16), byte(requestID >> 8), byte(requestID), // request-id
-		0x02, // job-attributes-tag
-		// job-id
-		0x21, 0x00, 0x06, 'j', 'o', 'b', '-', 'i', 'd',
-		0x00, 0x04, byte(jobID>>24), byte(jobID>>16), byte(jobID>>8), byte(jobID), // job-id
-		// job-state
-		0x21, 0x00, 0x09, 'j', 'o', 'b', '-', 's', 't', 'a', 't', 'e',
-		0x00, 0x04, 0x00, 0x00, 0x00, 0x03, // pending state
-		0x03, // end-of-attributes-tag
+	doc, err := a.readDocument(req, body, 1, a.config.MaxJobSize, printer.Caps.Formats)
+	if err != nil {
+		log.Printf("读取文档数据失败: %v", err)
+		return a.buildDocumentErrorResponse(req, err)
 	}
-	
-	log.Printf("接受打印任务，Job ID: %d", jobID)
+	job.Documents = []*PrintDocument{doc}
+	held := job.State == ipp.JobPendingHeld
+
+	a.registerJob(job, printer)
+
+	log.Printf("创建打印任务 - ID: %d, 名称: %s, 格式: %s, 大小: %d 字节",
+		job.ID, job.Name, doc.EffectiveFormat(), doc.Size)
+
+	// 异步执行实际打印，挂起的任务等待 Release-Job；读取文档期间队列已满时撤销任务
+	if !held {
+		if err := a.startJob(job); err != nil {
+			log.Printf("打印任务 ID: %d 无法加入队列，已撤销: %v", job.ID, err)
+			a.purgeJob(job)
+			return a.buildErrorResponse(req, ipp.StatusErrorBusy)
+		}
+	}
+
+	log.Printf("接受打印任务，Job ID: %d", job.ID)
+	return a.withUnsupported(a.buildJobResponse(req, job), unsupported)
+}
+
+// buildCreateJobResponse 构建 Create-Job 响应
+//
+// 任务以 pending-held（job-incoming）状态创建，收到 last-document=true 的
+// Send-Document 或 Close-Job 后再开始打印。
+func (a *AirPrintServer) buildCreateJobResponse(req *ipp.Message, printer *sharedPrinter) *ipp.Message {
+	job, unsupported := a.parseJobRequest(req, printer)
+	if rejected := a.rejectUnsupported(req, unsupported); rejected != nil {
+		return rejected
+	}
+	if a.queueFor(printer.Name).Full() {
+		return a.buildErrorResponse(req, ipp.StatusErrorBusy)
+	}
+	job.State = ipp.JobPendingHeld
+	job.Incoming = true
+
+	a.registerJob(job, printer)
+
+	log.Printf("创建多文档打印任务 - ID: %d, 名称: %s", job.ID, job.Name)
+	return a.withUnsupported(a.buildJobResponse(req, job), unsupported)
+}
+
+// buildSendDocumentResponse 构建 Send-Document 响应，将文档追加到 Create-Job 创建的任务
+func (a *AirPrintServer) buildSendDocumentResponse(req *ipp.Message, printer *sharedPrinter, body io.Reader) *ipp.Message {
+	job, errResponse := a.lookupJob(req, printer)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	lastDocument := false
+	if attr, ok := req.Operation().Get("last-document"); ok {
+		lastDocument, _ = attr.Bool()
+	} else {
+		return a.buildErrorResponse(req, ipp.StatusErrorBadRequest)
+	}
+
+	var incoming bool
+	var number int
+	var printerName string
+	limit := int64(0)
+	a.jobs.View(func() {
+		incoming = job.Incoming && job.State == ipp.JobPendingHeld
+		number = len(job.Documents) + 1
+		printerName = job.PrinterName
+		if a.config.MaxJobSize > 0 {
+			// 限制针对整个任务，扣除已接收文档的大小
+			limit = a.config.MaxJobSize - job.Size()
+			if limit <= 0 {
+				limit = -1
+			}
+		}
+	})
+	if !incoming {
+		return a.buildErrorResponse(req, ipp.StatusErrorNotPossible)
+	}
+	if limit < 0 {
+		return a.buildErrorResponse(req, ipp.StatusErrorRequestEntityTooLarge)
+	}
+
+	var formats []string
+	if printer := a.sharedPrinterNamed(printerName); printer != nil {
+		formats = printer.Caps.Formats
+	}
+	doc, err := a.readDocument(req, body, number, limit, formats)
+	if err != nil {
+		log.Printf("读取文档数据失败: %v", err)
+		return a.buildDocumentErrorResponse(req, err)
+	}
+
+	// 最后一个文档允许不带数据，仅用于结束任务
+	if doc.Size > 0 || !lastDocument {
+		// 接收文档期间任务可能已被取消或结束接收，此时不再追加，暂存的文档也不会再被释放
+		err := a.jobs.Update(job, func() error {
+			if !job.Incoming || job.State != ipp.JobPendingHeld {
+				return fmt.Errorf("job %d is no longer accepting documents", job.ID)
+			}
+			job.Documents = append(job.Documents, doc)
+			return nil
+		})
+		if err != nil {
+			a.spool.Remove(doc.Path)
+			log.Printf("丢弃打印任务 ID: %d 的文档 %d: %v", job.ID, doc.Number, err)
+			return a.buildErrorResponse(req, ipp.StatusErrorNotPossible)
+		}
+	} else {
+		a.spool.Remove(doc.Path)
+	}
+
+	log.Printf("打印任务 ID: %d 收到文档 %d - 格式: %s, 大小: %d 字节",
+		job.ID, doc.Number, doc.EffectiveFormat(), doc.Size)
+
+	if lastDocument {
+		a.closeJob(job)
+	}
+	return a.buildJobResponse(req, job)
+}
+
+// buildCloseJobResponse 构建 Close-Job 响应，结束文档接收并开始打印
+func (a *AirPrintServer) buildCloseJobResponse(req *ipp.Message, printer *sharedPrinter) *ipp.Message {
+	job, errResponse := a.lookupJob(req, printer)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	var incoming bool
+	a.jobs.View(func() {
+		incoming = job.Incoming
+	})
+	if !incoming {
+		return a.buildErrorResponse(req, ipp.StatusErrorNotPossible)
+	}
+
+	a.closeJob(job)
+	return a.buildJobResponse(req, job)
+}
+
+// closeJob 结束任务的文档接收，按 job-hold-until 决定开始打印或继续挂起
+func (a *AirPrintServer) closeJob(job *PrintJob) {
+	start := false
+	a.jobs.Update(job, func() error {
+		if !job.Incoming {
+			return nil
+		}
+		job.Incoming = false
+
+		switch {
+		case job.State != ipp.JobPendingHeld:
+			// 接收期间已被取消
+			return nil
+		case len(job.Documents) == 0:
+			log.Printf("打印任务 ID: %d 没有任何文档，已中止", job.ID)
+			job.Error = "no documents"
+			return a.jobs.transitionLocked(job, ipp.JobAborted)
+		case job.HoldUntil != "" && job.HoldUntil != "no-hold":
+			job.Reasons = defaultJobStateReasons(job)
+			return nil
+		}
+		start = true
+		return a.jobs.transitionLocked(job, ipp.JobPending)
+	})
+	if !start {
+		return
+	}
+
+	log.Printf("打印任务 ID: %d 文档接收完毕，共 %d 个文档", job.ID, len(job.Documents))
+	a.requeueJob(job)
+}
+
+// registerJob 记录目标打印机并将任务加入任务存储
+func (a *AirPrintServer) registerJob(job *PrintJob, printer *sharedPrinter) {
+	job.PrinterName = printer.Name
+	a.jobs.Add(job)
+}
+
+// buildJobResponse 构建包含任务标识与状态的成功响应
+func (a *AirPrintServer) buildJobResponse(req *ipp.Message, job *PrintJob) *ipp.Message {
+	response := a.newResponse(req, ipp.StatusOK)
+	a.jobs.View(func() {
+		response.AddGroup(ipp.TagJobGroup).Add(
+			ipp.IntAttr("job-id", ipp.TagInteger, job.ID),
+			ipp.StringAttr("job-uri", ipp.TagURI, a.jobURI(job)),
+			ipp.IntAttr("job-state", ipp.TagEnum, int(job.State)),
+			ipp.StringAttr("job-state-reasons", ipp.TagKeyword, job.Reasons...),
+		)
+	})
 	return response
 }
 
 // buildValidateJobResponse 构建验证任务响应
-func (a *AirPrintServer) buildValidateJobResponse(requestID uint32) []byte {
-	response := []byte{
-		0x01, 0x01, // IPP version 1.1
-		0x00, 0x00, // successful-ok
-		byte(requestID >> 24), byte(requestID >> 16), byte(requestID >> 8), byte(requestID), // request-id
-		0x03, // end-of-attributes-tag
+func (a *AirPrintServer) buildValidateJobResponse(req *ipp.Message, printer *sharedPrinter) *ipp.Message {
+	_, unsupported := a.parseJobRequest(req, printer)
+	if rejected := a.rejectUnsupported(req, unsupported); rejected != nil {
+		return rejected
+	}
+	// 没有文档内容可供识别，只检查明确指定的格式
+	if attr, ok := req.Operation().Get("document-format"); ok {
+		if format, _ := attr.Str(); format != "" && format != formatOctetStream &&
+			!containsString(printer.Caps.Formats, format) {
+			return a.buildDocumentErrorResponse(req, fmt.Errorf("%w: %s", ErrDocumentFormatNotSupported, format))
+		}
+	}
+	return a.withUnsupported(a.newResponse(req, ipp.StatusOK), unsupported)
+}
+
+// rejectUnsupported 在 ipp-attribute-fidelity 为 true 且存在不支持的属性时返回错误响应
+func (a *AirPrintServer) rejectUnsupported(req *ipp.Message, unsupported []ipp.Attribute) *ipp.Message {
+	if len(unsupported) == 0 {
+		return nil
+	}
+	fidelity := false
+	if attr, ok := req.Operation().Get("ipp-attribute-fidelity"); ok {
+		fidelity, _ = attr.Bool()
 	}
+	if !fidelity {
+		return nil
+	}
+
+	response := a.buildErrorResponse(req, ipp.StatusErrorAttributesOrValues)
+	response.AddGroup(ipp.TagUnsupportedGroup).Add(unsupported...)
 	return response
 }
 
-// buildErrorResponse 构建错误响应
-func (a *AirPrintServer) buildErrorResponse(requestID uint32, statusCode uint16) []byte {
-	response := []byte{
-		0x01, 0x01, // IPP version 1.1
-		byte(statusCode >> 8), byte(statusCode), // status code
-		byte(requestID >> 24), byte(requestID >> 16), byte(requestID >> 8), byte(requestID), // request-id
-		0x03, // end-of-attributes-tag
+// withUnsupported 将被忽略的属性附加到成功响应中，并将状态改为 successful-ok-ignored-or-substituted-attributes
+func (a *AirPrintServer) withUnsupported(response *ipp.Message, unsupported []ipp.Attribute) *ipp.Message {
+	if len(unsupported) == 0 {
+		return response
 	}
+	response.Code = uint16(ipp.StatusOKIgnoredOrSubstituted)
+	response.AddGroup(ipp.TagUnsupportedGroup).Add(unsupported...)
 	return response
 }
 
-// parseIPPPrintRequest 解析 IPP 打印请求
-func (a *AirPrintServer) parseIPPPrintRequest(body []byte) (jobName, documentFormat string, documentData []byte) {
+// buildErrorResponse 构建错误响应
+func (a *AirPrintServer) buildErrorResponse(req *ipp.Message, status ipp.Status) *ipp.Message {
+	return a.newResponse(req, status)
+}
+
+// parseJobRequest 解析 Print-Job / Create-Job / Validate-Job 请求中的任务属性，并返回不支持的任务模板属性
+func (a *AirPrintServer) parseJobRequest(req *ipp.Message, printer *sharedPrinter) (*PrintJob, []ipp.Attribute) {
 	// 设置默认值
-	jobName = "Untitled"
-	documentFormat = "application/octet-stream"
-	
-	// 简单解析：找到文档数据的开始位置
-	// 在实际的 IPP 实现中，这需要完整解析 IPP 属性
-	dataStart := -1
-	
-	// 查找 end-of-attributes-tag (0x03)
-	for i := 8; i < len(body)-1; i++ {
-		if body[i] == 0x03 {
-			dataStart = i + 1
-			break
+	job := &PrintJob{
+		Name:  "Untitled",
+		State: ipp.JobPending,
+	}
+
+	op := req.Operation()
+	if name, ok := op.Get("job-name"); ok {
+		if s, ok := name.Str(); ok && s != "" {
+			job.Name = s
 		}
 	}
-	
-	if dataStart > 0 && dataStart < len(body) {
-		documentData = body[dataStart:]
-		log.Printf("提取文档数据: %d 字节", len(documentData))
+	if user, ok := op.Get("requesting-user-name"); ok {
+		if s, ok := user.Str(); ok {
+			job.User = s
+		}
+	}
+
+	// job-hold-until 为 no-hold 以外的值时，任务创建后保持挂起，等待 Release-Job
+	jobAttrs := req.Group(ipp.TagJobGroup)
+	if hold, ok := jobAttrs.Get("job-hold-until"); ok {
+		if s, ok := hold.Str(); ok && s != "no-hold" {
+			job.HoldUntil = s
+			job.State = ipp.JobPendingHeld
+		}
+	}
+
+	// 份数、单双面、纸张等任务模板属性，按目标打印机的能力校验
+	template, unsupported := parseJobTemplate(jobAttrs, printer.Caps.templateSupport())
+	job.Template = template
+
+	return job, unsupported
+}
+
+// readDocument 读取请求中的文档属性，并将属性部分之后的文档数据写入暂存目录
+//
+// limit 为允许的最大字节数，0 表示不限制。formats 为打印机的 document-format-supported，
+// 文档格式不在其中时不暂存文档并返回 ErrDocumentFormatNotSupported；nil 表示不检查。
+func (a *AirPrintServer) readDocument(req *ipp.Message, body io.Reader, number int, limit int64, formats []string) (*PrintDocument, error) {
+	doc := &PrintDocument{
+		Number: number,
+		Format: formatOctetStream,
+	}
+
+	op := req.Operation()
+	if format, ok := op.Get("document-format"); ok {
+		if s, ok := format.Str(); ok && s != "" {
+			doc.Format = s
+		}
+	}
+	if name, ok := op.Get("document-name"); ok {
+		doc.Name, _ = name.Str()
+	}
+
+	// 按文档开头的特征字节识别格式，客户端指定的格式与内容不符时只记录日志
+	doc.Detected, body = sniffFormat(body)
+	if doc.Format != formatOctetStream && doc.Detected != "" && doc.Detected != doc.Format {
+		log.Printf("文档格式 %s 与识别出的格式 %s 不一致，按 %s 处理", doc.Format, doc.Detected, doc.Format)
+	}
+	if formats != nil && !formatSupported(doc, formats) {
+		return nil, fmt.Errorf("%w: %s", ErrDocumentFormatNotSupported, doc.EffectiveFormat())
+	}
+
+	// 属性部分之后的全部数据都是文档内容，直接从请求流写入磁盘
+	path, size, err := a.spool.Write(body, limit, doc.EffectiveFormat())
+	if err != nil {
+		return nil, err
+	}
+	doc.Path = path
+	doc.Size = size
+	log.Printf("文档数据已暂存: %s (%d 字节)", path, size)
+
+	return doc, nil
+}
+
+// documentErrorStatus 将读取文档时的错误转换为 IPP 状态码
+func documentErrorStatus(err error) ipp.Status {
+	switch {
+	case errors.Is(err, ErrDocumentTooLarge):
+		return ipp.StatusErrorRequestEntityTooLarge
+	case errors.Is(err, ErrDocumentFormatNotSupported):
+		return ipp.StatusErrorDocumentFormatNotSupported
+	}
+	return ipp.StatusErrorBadRequest
+}
+
+// buildDocumentErrorResponse 构建读取文档失败的错误响应，格式不受支持时在 unsupported 组中返回 document-format
+func (a *AirPrintServer) buildDocumentErrorResponse(req *ipp.Message, err error) *ipp.Message {
+	response := a.buildErrorResponse(req, documentErrorStatus(err))
+	if errors.Is(err, ErrDocumentFormatNotSupported) {
+		if attr, ok := req.Operation().Get("document-format"); ok {
+			response.AddGroup(ipp.TagUnsupportedGroup).Add(attr)
+		}
+	}
+	return response
+}
+
+// queueFor 返回目标打印机的任务队列，首次使用时按配置创建
+func (a *AirPrintServer) queueFor(printerName string) *PrintQueue {
+	a.queuesMu.Lock()
+	defer a.queuesMu.Unlock()
+
+	queue, ok := a.queues[printerName]
+	if !ok {
+		settings := a.config.queueConfig(printerName)
+		if a.classes.IsClass(printerName) && a.config.Printers[printerName].Queue.Concurrency == nil {
+			// 打印机类默认每个成员同时执行一个任务
+			settings.Concurrency = a.classes.ClassConcurrency(printerName)
+		}
+		queue = NewPrintQueue(printerName, settings.Concurrency, settings.Capacity, a.executePrintJob)
+		a.queues[printerName] = queue
+	}
+	return queue
+}
+
+// startJob 将新接受的任务加入目标打印机的队列，队列已满时返回 ErrQueueFull
+func (a *AirPrintServer) startJob(job *PrintJob) error {
+	return a.jobs.Update(job, func() error {
+		return a.enqueueLocked(job, true)
+	})
+}
+
+// requeueJob 将已接受的任务（服务重启前未完成、Create-Job 接收完文档）加入队列，不受队列容量限制
+func (a *AirPrintServer) requeueJob(job *PrintJob) {
+	a.jobs.Update(job, func() error {
+		return a.enqueueLocked(job, false)
+	})
+}
+
+// enqueueLocked 将任务加入目标打印机的队列，ctx 在任务被取消时结束；limit 为 true 时
+// 队列已满返回 ErrQueueFull，任务不变（调用方需持有任务存储的锁）
+func (a *AirPrintServer) enqueueLocked(job *PrintJob, limit bool) error {
+	queue := a.queueFor(job.PrinterName)
+	ctx, cancel := context.WithCancel(context.Background())
+	if limit {
+		if err := queue.Push(ctx, job, job.ID, job.Template.priority()); err != nil {
+			cancel()
+			return err
+		}
 	} else {
-		log.Printf("未找到文档数据")
-		documentData = []byte{}
+		queue.Requeue(ctx, job, job.ID, job.Template.priority())
 	}
-	
-	// 简单解析一些常见属性（更完整的实现需要全面解析 IPP 属性）
-	bodyStr := string(body)
-	if strings.Contains(bodyStr, "application/pdf") {
-		documentFormat = "application/pdf"
-	} else if strings.Contains(bodyStr, "text/plain") {
-		documentFormat = "text/plain"
-	} else if strings.Contains(bodyStr, "image/jpeg") {
-		documentFormat = "image/jpeg"
-	} else if strings.Contains(bodyStr, "image/png") {
-		documentFormat = "image/png"
+
+	if job.cancel != nil {
+		// 上一次执行（如已取消的任务）不再需要
+		job.cancel()
 	}
-	
-	return jobName, documentFormat, documentData
+	job.cancel = cancel
+	return nil
+}
+
+// dequeueJob 删除任务在目标打印机队列中等待执行的条目，任务被挂起或取消时调用
+func (a *AirPrintServer) dequeueJob(job *PrintJob) {
+	var printerName string
+	a.jobs.View(func() {
+		printerName = job.PrinterName
+	})
+	a.queueFor(printerName).Remove(job)
 }
 
 // executePrintJob 执行实际的打印任务
-func (a *AirPrintServer) executePrintJob(job *PrintJob) {
+//
+// 目标为打印机类时按选择策略分配成员，其余可用成员作为备用。打印失败时按重试策略
+// 重试，仍然失败则依次改用备用打印机；全部失败后按配置中止任务，或停止打印机队列
+// 等待操作员恢复后从头再试。文档本身无法打印（PermanentError）时立即中止任务。
+func (a *AirPrintServer) executePrintJob(ctx context.Context, job *PrintJob) {
+	// 任务在开始执行前可能已被挂起或取消
+	if ctx.Err() != nil {
+		log.Printf("打印任务 ID: %d 已取消，跳过", job.ID)
+		return
+	}
+	if err := a.jobs.Transition(job, ipp.JobProcessing); err != nil {
+		log.Printf("打印任务 ID: %d 不再等待执行，跳过: %v", job.ID, err)
+		return
+	}
 	log.Printf("开始执行打印任务 ID: %d", job.ID)
-	
-	job.Status = "processing"
-	
-	// 创建临时文件来保存文档数据
-	tempFile, err := a.createTempFile(job)
+
+	// 各文档已暂存在磁盘上，按顺序作为同一个系统任务提交
+	var files, formats []string
+	var printerName, title string
+	var template JobTemplate
+	a.jobs.View(func() {
+		for _, doc := range job.Documents {
+			files = append(files, doc.Path)
+			formats = append(formats, doc.EffectiveFormat())
+		}
+		printerName = job.PrinterName
+		title = job.Name
+		template = job.Template
+	})
+
+	retry := a.config.retryConfig(printerName)
+	for {
+		// 每次从头执行时重新选择成员，跳过当前不可用的成员
+		targets, selectErr := a.classes.Select(printerName)
+		if selectErr != nil {
+			log.Printf("打印任务 ID: %d 无法分配打印机: %v", job.ID, selectErr)
+		}
+		targets = append(targets, a.config.failover(printerName)...)
+		lastErr := selectErr
+		for i, target := range targets {
+			for attempt := 1; attempt <= retry.MaxAttempts; attempt++ {
+				// 执行打印命令前再次确认任务未被取消
+				if ctx.Err() != nil {
+					log.Printf("打印任务 ID: %d 已取消", job.ID)
+					return
+				}
+
+				a.classes.Begin(target)
+				remoteID, err := a.sendJob(ctx, files, formats, target, title, template)
+				a.classes.Done(target)
+				if ctx.Err() != nil {
+					// 打印命令执行期间任务被取消：已提交到系统队列的部分一并取消
+					if remoteID != "" {
+						if err := a.backends.CancelJob(remoteID); err != nil {
+							log.Printf("取消系统打印任务 %s 失败: %v", remoteID, err)
+						}
+					}
+					log.Printf("打印任务 ID: %d 已取消", job.ID)
+					return
+				}
+				if err == nil {
+					a.completeJob(job, remoteID, target, i > 0 || selectErr != nil, lastErr)
+					return
+				}
+
+				if isPermanent(err) {
+					// 文档本身无法打印：重试或改用备用打印机都不会成功，也不停止打印机队列
+					a.jobs.Update(job, func() error {
+						job.Error = err.Error()
+						return a.jobs.transitionLocked(job, ipp.JobAborted, "aborted-by-system", "document-unprintable-error")
+					})
+					log.Printf("打印任务 ID: %d 的文档无法打印，已中止: %v", job.ID, err)
+					return
+				}
+
+				lastErr = err
+				log.Printf("打印任务 ID: %d 在打印机 %s 上第 %d 次尝试失败: %v", job.ID, target, attempt, err)
+				if attempt < retry.MaxAttempts && !a.waitRetry(ctx, job, retry.delay(attempt), err) {
+					log.Printf("打印任务 ID: %d 已取消", job.ID)
+					return
+				}
+			}
+		}
+
+		if !retry.StopOnFailure {
+			a.jobs.Update(job, func() error {
+				job.Error = lastErr.Error()
+				return a.jobs.transitionLocked(job, ipp.JobAborted)
+			})
+			log.Printf("打印任务 ID: %d 多次尝试均失败，已中止", job.ID)
+			return
+		}
+
+		// 停止打印机队列，任务保持 processing-stopped，直到操作员通过 Resume-Printer 恢复
+		queue := a.queueFor(printerName)
+		queue.Stop("other-error")
+		a.jobs.Update(job, func() error {
+			job.Error = lastErr.Error()
+			return a.jobs.transitionLocked(job, ipp.JobProcessingStopped, "printer-stopped")
+		})
+		log.Printf("打印任务 ID: %d 多次尝试均失败，打印机 %s 的队列已停止", job.ID, printerName)
+		if !queue.WaitResumed(ctx) {
+			log.Printf("打印任务 ID: %d 已取消", job.ID)
+			return
+		}
+		if err := a.jobs.Transition(job, ipp.JobProcessing); err != nil {
+			return
+		}
+		log.Printf("打印机 %s 的队列已恢复，重新执行打印任务 ID: %d", printerName, job.ID)
+	}
+}
+
+// waitRetry 在重试前等待 delay，期间任务处于 processing-stopped；任务被取消时返回 false
+func (a *AirPrintServer) waitRetry(ctx context.Context, job *PrintJob, delay time.Duration, cause error) bool {
+	err := a.jobs.Update(job, func() error {
+		job.Error = cause.Error()
+		return a.jobs.transitionLocked(job, ipp.JobProcessingStopped, "printer-stopped", "job-restartable")
+	})
 	if err != nil {
-		log.Printf("创建临时文件失败: %v", err)
-		job.Status = "aborted"
-		return
+		return false
 	}
-	defer os.Remove(tempFile) // 清理临时文件
-	
-	// 执行打印命令
-	err = a.printToSystem(tempFile, job.PrinterName)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+	}
+	return a.jobs.Transition(job, ipp.JobProcessing) == nil
+}
+
+// completeJob 将任务标记为完成；改用备用打印机完成时在 job-state-reasons 与 job-state-message 中注明
+func (a *AirPrintServer) completeJob(job *PrintJob, remoteID, target string, failedOver bool, cause error) {
+	err := a.jobs.Update(job, func() error {
+		job.RemoteJobID = remoteID
+		if !failedOver {
+			job.Error = ""
+			return a.jobs.transitionLocked(job, ipp.JobCompleted)
+		}
+		job.Error = fmt.Sprintf("printed on %s after %s failed: %v", target, job.PrinterName, cause)
+		return a.jobs.transitionLocked(job, ipp.JobCompleted, "job-completed-with-warnings")
+	})
 	if err != nil {
-		log.Printf("打印失败: %v", err)
-		job.Status = "aborted"
+		log.Printf("打印任务 ID: %d 状态更新失败: %v", job.ID, err)
+		return
+	}
+	if failedOver {
+		log.Printf("打印任务 ID: %d 已改用备用打印机 %s 完成", job.ID, target)
 		return
 	}
-	
-	job.Status = "completed"
 	log.Printf("打印任务 ID: %d 完成", job.ID)
 }
 
-// createTempFile 创建临时文件
-func (a *AirPrintServer) createTempFile(job *PrintJob) (string, error) {
-	// 根据文档格式确定文件扩展名
-	var ext string
-	switch job.Format {
-	case "application/pdf":
-		ext = ".pdf"
-	case "text/plain":
-		ext = ".txt"
-	case "image/jpeg":
-		ext = ".jpg"
-	case "image/png":
-		ext = ".png"
-	default:
-		ext = ".dat"
+// sendJob 将任务发送到目标打印机：使用网络后端的打印机直接发送，其余提交到系统打印队列
+//
+// formats 为各文件记录的文档格式；title 为任务名称（job-name），作为打印机或系统打印队列
+// 中显示的任务标题。
+func (a *AirPrintServer) sendJob(ctx context.Context, filePaths, formats []string, printerName, title string, template JobTemplate) (string, error) {
+	if sender := a.backends.Sender(printerName); sender != nil {
+		return sender.SendJob(ctx, printerName, title, filePaths, formats, template)
 	}
-	
-	// 创建临时文件
-	tempFile := filepath.Join(os.TempDir(), fmt.Sprintf("airprint_%d_%d%s", 
-		job.ID, time.Now().Unix(), ext))
-	
-	err := ioutil.WriteFile(tempFile, job.Data, 0644)
+
+	// 纯文本由本服务排版，不依赖系统的文本过滤器与字体
+	files, formats, cleanup, err := a.renderTextFiles(filePaths, formats, printerName, template)
 	if err != nil {
-		return "", fmt.Errorf("写入临时文件失败: %v", err)
+		return "", err
 	}
-	
-	log.Printf("创建临时文件: %s", tempFile)
-	return tempFile, nil
+	defer cleanup()
+	if len(files) == 0 {
+		log.Printf("打印任务只包含空白的纯文本，没有需要打印的页面")
+		return "", nil
+	}
+	return a.printToSystem(ctx, files, formats, printerName, title, template)
 }
 
-// printToSystem 发送文件到系统打印机
-func (a *AirPrintServer) printToSystem(filePath, printerName string) error {
+// lpRequestIDPattern 匹配 lp 输出中的任务 ID，如 "request id is Printer-42 (1 file(s))"
+var lpRequestIDPattern = regexp.MustCompile(`request id is (\S+)`)
+
+// printToSystem 将一个或多个文件作为同一个任务发送到系统打印机，返回系统打印队列中的任务 ID
+//
+// 任务模板（份数、单双面、纸张等）转换为 lp 的对应选项；formats 为各文件的文档格式。
+func (a *AirPrintServer) printToSystem(ctx context.Context, filePaths, formats []string, printerName, title string, template JobTemplate) (string, error) {
 	var cmd *exec.Cmd
-	
+
 	// 根据操作系统选择打印命令
 	switch runtime.GOOS {
-	case "darwin": // macOS
+	case "darwin", "linux":
+		// macOS 与 Linux 均使用 CUPS 的 lp 命令，其输出包含可用于取消的任务 ID
+		var args []string
 		if printerName != "" {
-			// 使用指定打印机
-			cmd = exec.Command("lpr", "-P", printerName, filePath)
-		} else {
-			// 使用默认打印机
-			cmd = exec.Command("lpr", filePath)
+			// 使用指定打印机，否则使用默认打印机
+			args = append(args, "-d", printerName)
 		}
-	case "linux":
-		if printerName != "" {
-			cmd = exec.Command("lp", "-d", printerName, filePath)
-		} else {
-			cmd = exec.Command("lp", filePath)
+		if title != "" {
+			args = append(args, "-t", title)
 		}
+		args = append(args, template.lpOptions()...)
+		if printerLanguageFiles(formats) {
+			// 标签打印机指令 CUPS 无法识别，原样发送到打印机
+			args = append(args, "-o", "raw")
+		}
+		args = append(args, filePaths...)
+		cmd = exec.CommandContext(ctx, "lp", args...)
 	case "windows":
 		// Windows 打印命令（需要进一步实现）
-		return fmt.Errorf("Windows 打印支持正在开发中")
+		return "", fmt.Errorf("Windows 打印支持正在开发中")
 	default:
-		return fmt.Errorf("不支持的操作系统: %s", runtime.GOOS)
+		return "", fmt.Errorf("不支持的操作系统: %s", runtime.GOOS)
 	}
-	
+
 	log.Printf("执行打印命令: %s", cmd.String())
-	
+
 	// 执行命令
 	output, err := cmd.CombinedOutput()
+
+	remoteID := ""
+	if m := lpRequestIDPattern.FindSubmatch(output); m != nil {
+		remoteID = string(m[1])
+	}
 	if err != nil {
-		return fmt.Errorf("打印命令执行失败: %v, 输出: %s", err, string(output))
+		return remoteID, fmt.Errorf("打印命令执行失败: %v, 输出: %s", err, string(output))
 	}
-	
+
 	log.Printf("打印命令执行成功，输出: %s", string(output))
+	return remoteID, nil
+}
+
+// cancelSystemJob 取消已提交到系统打印队列的任务
+func cancelSystemJob(remoteID string) error {
+	cmd := exec.Command("cancel", remoteID)
+	log.Printf("执行取消命令: %s", cmd.String())
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("取消命令执行失败: %v, 输出: %s", err, string(output))
+	}
 	return nil
 }
 
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"airprint-service/ipp"
+)
+
+// PrinterCapabilities 打印机能力
+//
+// mDNS TXT 记录与 IPP 打印机属性（包括任务模板的校验范围）都由它生成，保证两者一致。
+type PrinterCapabilities struct {
+	MakeAndModel string
+	Color        bool
+	Duplex       bool
+	Resolutions  []int    // 支持的分辨率（dpi），第一个为默认值
+	Media        []string // PWG 5101.1 媒体名称
+	DefaultMedia string
+	Formats      []string // 支持的文档格式（MIME 类型）
+}
+
+// CapabilityProvider 能够查询打印机能力的打印机管理器
+type CapabilityProvider interface {
+	GetCapabilities(name string) (PrinterCapabilities, error)
+}
+
+// defaultCapabilities 返回无法查询打印机时使用的通用能力
+func defaultCapabilities() PrinterCapabilities {
+	return PrinterCapabilities{
+		Color:       true,
+		Duplex:      false,
+		Resolutions: []int{300},
+		Media: []string{
+			"iso_a4_210x297mm",
+			"iso_a5_148x210mm",
+			"iso_a6_105x148mm",
+			"na_letter_8.5x11in",
+			"na_legal_8.5x14in",
+			"na_index-4x6_4x6in",
+		},
+		DefaultMedia: "iso_a4_210x297mm",
+		Formats: []string{
+			"application/pdf",
+			"application/postscript",
+			"image/urf",
+			"image/pwg-raster",
+			"image/jpeg",
+			"image/png",
+			"text/plain",
+			"application/octet-stream",
+		},
+	}
+}
+
+// printerCapabilities 从打印机管理器查询打印机能力，未知的部分使用默认值
+func printerCapabilities(manager PrinterManager, name string) PrinterCapabilities {
+	defaults := defaultCapabilities()
+	provider, ok := manager.(CapabilityProvider)
+	if !ok || name == "" {
+		return defaults
+	}
+
+	caps, err := provider.GetCapabilities(name)
+	if err != nil {
+		log.Printf("查询打印机 %s 的能力失败，使用默认能力: %v", name, err)
+		return defaults
+	}
+	if len(caps.Resolutions) == 0 {
+		caps.Resolutions = defaults.Resolutions
+	}
+	if len(caps.Media) == 0 {
+		caps.Media = defaults.Media
+		caps.DefaultMedia = defaults.DefaultMedia
+	}
+	if caps.DefaultMedia == "" || !containsString(caps.Media, caps.DefaultMedia) {
+		caps.DefaultMedia = caps.Media[0]
+	}
+	if len(caps.Formats) == 0 {
+		caps.Formats = defaults.Formats
+	}
+	return caps
+}
+
+// templateSupport 返回任务模板属性的支持范围
+func (c PrinterCapabilities) templateSupport() TemplateSupport {
+	support := TemplateSupport{
+		MaxCopies:      999,
+		Sides:          []string{"one-sided"},
+		Media:          c.Media,
+		DefaultMedia:   c.DefaultMedia,
+		Orientations:   []int{3, 4, 5, 6},
+		PrintQualities: []int{3, 4, 5},
+		ColorModes:     []string{"monochrome"},
+	}
+	if c.Duplex {
+		support.Sides = append(support.Sides, "two-sided-long-edge", "two-sided-short-edge")
+	}
+	if c.Color {
+		support.ColorModes = []string{"auto", "monochrome", "color"}
+	}
+	return support
+}
+
+// urf 返回 Apple Raster 能力描述，用于 URF TXT 键与 urf-supported 属性
+func (c PrinterCapabilities) urf() []string {
+	values := []string{"W8"}
+	if c.Color {
+		values = append(values, "SRGB24")
+	}
+	values = append(values, "CP1")
+
+	var res []string
+	for _, dpi := range c.Resolutions {
+		res = append(res, strconv.Itoa(dpi))
+	}
+	values = append(values, "RS"+strings.Join(res, "-"), "V1.4")
+	if c.Duplex {
+		values = append(values, "DM1")
+	}
+	return values
+}
+
+// pwgRasterTypes 返回 pwg-raster-document-type-supported 属性的取值
+func (c PrinterCapabilities) pwgRasterTypes() []string {
+	types := []string{"black_1", "sgray_8"}
+	if c.Color {
+		types = append(types, "srgb_8")
+	}
+	return types
+}
+
+// paperMax 按支持的最大纸张返回 PaperMax TXT 键的取值
+func (c PrinterCapabilities) paperMax() string {
+	maxWidth, maxLength := 0, 0
+	for _, name := range c.Media {
+		w, h, ok := mediaSize(name)
+		if !ok {
+			continue
+		}
+		if w > h {
+			w, h = h, w
+		}
+		if w > maxWidth {
+			maxWidth = w
+		}
+		if h > maxLength {
+			maxLength = h
+		}
+	}
+
+	// 尺寸单位为 1/100 毫米
+	switch {
+	case maxWidth < 21000 || maxLength < 29700:
+		return "<legal-A4"
+	case maxWidth <= 21590 && maxLength <= 35560:
+		return "legal-A4"
+	case maxWidth <= 29700 && maxLength <= 43180:
+		return "tabloid-A3"
+	case maxWidth <= 45800 && maxLength <= 64800:
+		return "isoC-A2"
+	}
+	return ">isoC-A2"
+}
+
+// txtRecords 生成与能力相关的 mDNS TXT 记录
+func (c PrinterCapabilities) txtRecords() []string {
+	return []string{
+		"pdl=" + strings.Join(c.Formats, ","),
+		"URF=" + strings.Join(c.urf(), ","),
+		"Color=" + txtBool(c.Color),
+		"Duplex=" + txtBool(c.Duplex),
+		"PaperMax=" + c.paperMax(),
+		fmt.Sprintf("printer-type=0x%X", c.printerType()),
+	}
+}
+
+// printerType 返回 CUPS printer-type 位掩码
+func (c PrinterCapabilities) printerType() int {
+	// 远程打印机、可复印、可排序等固定位
+	t := 0x809042
+	if c.Color {
+		t |= 0x8
+	} else {
+		t |= 0x4
+	}
+	if c.Duplex {
+		t |= 0x10
+	}
+	return t
+}
+
+// printerAttributes 生成与能力相关的 IPP 打印机属性
+func (c PrinterCapabilities) printerAttributes() []ipp.Attribute {
+	var resolutions []ipp.Value
+	for _, dpi := range c.Resolutions {
+		resolutions = append(resolutions, ipp.Resolution{Xres: int32(dpi), Yres: int32(dpi), Units: ipp.UnitsDPI})
+	}
+
+	attrs := []ipp.Attribute{
+		ipp.BoolAttr("color-supported", c.Color),
+		ipp.MakeAttr("printer-resolution-supported", ipp.TagResolution, resolutions...),
+		ipp.MakeAttr("printer-resolution-default", ipp.TagResolution, resolutions[0]),
+		ipp.StringAttr("document-format-supported", ipp.TagMimeType, c.Formats...),
+		ipp.StringAttr("document-format-default", ipp.TagMimeType, "application/octet-stream"),
+		ipp.StringAttr("urf-supported", ipp.TagKeyword, c.urf()...),
+	}
+	if containsString(c.Formats, "image/pwg-raster") {
+		attrs = append(attrs,
+			ipp.MakeAttr("pwg-raster-document-resolution-supported", ipp.TagResolution, resolutions...),
+			ipp.StringAttr("pwg-raster-document-type-supported", ipp.TagKeyword, c.pwgRasterTypes()...),
+			ipp.StringAttr("pwg-raster-document-sheet-back", ipp.TagKeyword, "normal"),
+		)
+	}
+	if c.MakeAndModel != "" {
+		attrs = append(attrs, ipp.StringAttr("printer-make-and-model", ipp.TagText, c.MakeAndModel))
+	}
+	return append(attrs, c.templateSupport().printerAttributes()...)
+}
+
+// txtBool 将布尔值转换为 TXT 记录中的 T/F
+func txtBool(b bool) string {
+	if b {
+		return "T"
+	}
+	return "F"
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Config AirPrint 服务配置
+type Config struct {
+	// Port IPP/HTTP 监听端口
+	Port int `json:"port"`
+	// SpoolDir 接收文档的暂存目录，重启后重新排队的任务从这里读取文档
+	SpoolDir string `json:"spool_dir"`
+	// DataDir 保存服务状态（打印机 UUID、任务历史等）的目录
+	DataDir string `json:"data_dir"`
+	// MaxJobSize 单个任务全部文档的最大字节数，0 表示不限制
+	MaxJobSize int64 `json:"max_job_size"`
+	// Retention 已结束任务的保留策略
+	Retention RetentionConfig `json:"retention"`
+	// Queue 各打印机任务队列的默认设置
+	Queue QueueConfig `json:"queue"`
+	// Retry 打印失败后的默认重试策略
+	Retry RetryConfig `json:"retry"`
+	// Printers 按打印队列名称单独设置的打印机配置
+	Printers map[string]PrinterConfig `json:"printers"`
+	// Classes 打印机类（打印机池），每个类作为一台打印机共享，任务按策略分配给成员
+	Classes map[string]ClassConfig `json:"classes"`
+	// Text 纯文本文档的默认排版设置
+	Text TextConfig `json:"text"`
+	// AdminUsers 允许远程执行 Purge-Jobs、Pause-Printer、Resume-Printer 等操作员操作的用户（requesting-user-name），
+	// 本机发出的请求总是允许
+	AdminUsers []string `json:"admin_users"`
+}
+
+// QueueConfig 任务队列设置
+type QueueConfig struct {
+	// Concurrency 同一台打印机同时执行的任务数
+	Concurrency int `json:"concurrency"`
+	// Capacity 等待执行的任务数上限，队列已满时返回 server-error-busy，0 表示不限制
+	Capacity int `json:"capacity"`
+}
+
+// RetryConfig 打印失败后的重试策略
+type RetryConfig struct {
+	// MaxAttempts 每台打印机最多尝试的次数
+	MaxAttempts int `json:"max_attempts"`
+	// InitialDelay 第一次重试前等待的秒数，之后每次加倍
+	InitialDelay int `json:"initial_delay"`
+	// MaxDelay 重试等待的最大秒数
+	MaxDelay int `json:"max_delay"`
+	// StopOnFailure 为 true 时全部尝试失败后停止打印机队列，等待操作员通过 Resume-Printer 恢复；
+	// 为 false 时中止任务
+	StopOnFailure bool `json:"stop_on_failure"`
+}
+
+// delay 返回第 attempt 次失败后重试前的等待时间，MaxDelay 大于 0 时不超过 MaxDelay
+func (r RetryConfig) delay(attempt int) time.Duration {
+	delay := time.Duration(r.InitialDelay) * time.Second
+	max := time.Duration(r.MaxDelay) * time.Second
+	for i := 1; i < attempt && delay > 0; i++ {
+		if r.MaxDelay > 0 && delay >= max {
+			break
+		}
+		delay *= 2
+	}
+	if r.MaxDelay > 0 && delay > max {
+		return max
+	}
+	return delay
+}
+
+// validate 检查重试策略的取值
+func (r RetryConfig) validate() error {
+	if r.MaxAttempts < 0 || r.InitialDelay < 0 || r.MaxDelay < 0 {
+		return fmt.Errorf("retry settings must not be negative")
+	}
+	if r.MaxDelay > 0 && r.InitialDelay > r.MaxDelay {
+		return fmt.Errorf("retry initial_delay %d exceeds max_delay %d", r.InitialDelay, r.MaxDelay)
+	}
+	return nil
+}
+
+// 打印机后端类型
+const (
+	BackendSystem = "system" // 系统打印队列（CUPS lp），默认
+	BackendRaw    = "raw"    // 原始 TCP 端口（9100 / JetDirect）
+	BackendLPD    = "lpd"    // LPD 协议（RFC 1179，端口 515）
+	BackendSBPL   = "sbpl"   // 转换为 SATO SBPL 指令后通过原始 TCP 端口发送
+	BackendZPL    = "zpl"    // 转换为 Zebra ZPL II 指令后通过原始 TCP 端口发送
+	BackendTSPL   = "tspl"   // 转换为 TSC TSPL/TSPL2 指令后通过原始 TCP 端口发送
+)
+
+// PrinterConfig 单台打印机的配置，未设置的字段使用全局默认值
+type PrinterConfig struct {
+	// Backend 打印机后端，为空时使用系统打印队列；非系统后端的打印机不需要在系统中配置
+	Backend string `json:"backend,omitempty"`
+	// Address 网络打印机地址 host[:port]
+	Address string `json:"address,omitempty"`
+	// Description 显示名称，为空时使用打印队列名称
+	Description string `json:"description,omitempty"`
+	// Formats 原始 TCP 与 LPD 后端的打印机能直接处理的文档格式（MIME 类型），
+	// 如 application/postscript；任务数据原样发送，不做转换
+	Formats []string `json:"formats,omitempty"`
+	// Color 原始 TCP 与 LPD 后端的打印机是否支持彩色
+	Color bool `json:"color,omitempty"`
+	// Raw 原始 TCP 后端的连接设置
+	Raw RawConfig `json:"raw"`
+	// LPD LPD 后端的队列设置
+	LPD LPDConfig `json:"lpd"`
+	// Label 标签打印机后端的标签设置
+	Label LabelConfig        `json:"label"`
+	Queue PrinterQueueConfig `json:"queue"`
+	Retry *RetryConfig       `json:"retry,omitempty"`
+	// Text 纯文本文档的排版设置，为空时使用全局设置
+	Text *TextConfig `json:"text,omitempty"`
+	// Failover 本打印机全部尝试失败后依次改用的备用打印机
+	Failover []string `json:"failover,omitempty"`
+}
+
+// PrinterQueueConfig 单台打印机的任务队列设置，未设置（null）的字段使用全局设置
+//
+// 字段为指针，以便单独设置 capacity 为 0（不限制）覆盖全局的容量上限。
+type PrinterQueueConfig struct {
+	Concurrency *int `json:"concurrency,omitempty"`
+	Capacity    *int `json:"capacity,omitempty"`
+}
+
+// queueConfig 返回打印机实际使用的队列设置
+func (c Config) queueConfig(name string) QueueConfig {
+	queue := c.Queue
+	if printer, ok := c.Printers[name]; ok {
+		if printer.Queue.Concurrency != nil {
+			queue.Concurrency = *printer.Queue.Concurrency
+		}
+		if printer.Queue.Capacity != nil {
+			queue.Capacity = *printer.Queue.Capacity
+		}
+	}
+	return queue
+}
+
+// retryConfig 返回打印机实际使用的重试策略
+func (c Config) retryConfig(name string) RetryConfig {
+	retry := c.Retry
+	if printer, ok := c.Printers[name]; ok && printer.Retry != nil {
+		retry = *printer.Retry
+	}
+	if retry.MaxAttempts < 1 {
+		retry.MaxAttempts = 1
+	}
+	return retry
+}
+
+// textConfig 返回打印机实际使用的纯文本排版设置
+func (c Config) textConfig(name string) TextConfig {
+	if printer, ok := c.Printers[name]; ok && printer.Text != nil {
+		return *printer.Text
+	}
+	return c.Text
+}
+
+// failover 返回打印机的备用打印机
+func (c Config) failover(name string) []string {
+	return c.Printers[name].Failover
+}
+
+// RawConfig 原始 TCP 后端的连接设置
+type RawConfig struct {
+	// ConnectTimeout 建立连接的超时秒数
+	ConnectTimeout int `json:"connect_timeout"`
+	// WriteTimeout 写入每个数据块的超时秒数
+	WriteTimeout int `json:"write_timeout"`
+	// ChunkSize 每次写入的字节数
+	ChunkSize int `json:"chunk_size"`
+	// IdleTimeout 任务结束后保持连接的秒数，期间的新任务复用该连接；0 表示每个任务结束后断开
+	IdleTimeout int `json:"idle_timeout"`
+}
+
+// withDefaults 返回未设置的字段使用默认值后的连接设置
+func (r RawConfig) withDefaults() RawConfig {
+	if r.ConnectTimeout <= 0 {
+		r.ConnectTimeout = 10
+	}
+	if r.WriteTimeout <= 0 {
+		r.WriteTimeout = 30
+	}
+	if r.ChunkSize <= 0 {
+		r.ChunkSize = 32 << 10
+	}
+	return r
+}
+
+// LPDConfig LPD 后端的队列设置
+type LPDConfig struct {
+	// Queue 打印服务器上的队列名称
+	Queue string `json:"queue"`
+	// Timeout 连接与每一步读写的超时秒数
+	Timeout int `json:"timeout"`
+}
+
+// withDefaults 返回未设置的字段使用默认值后的队列设置
+func (l LPDConfig) withDefaults() LPDConfig {
+	if l.Queue == "" {
+		l.Queue = lpdDefaultQueue
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = 30
+	}
+	return l
+}
+
+// LabelConfig 标签打印机的标签设置
+type LabelConfig struct {
+	// Width 标签宽度（毫米）
+	Width float64 `json:"width"`
+	// Height 标签长度（毫米，走纸方向）
+	Height float64 `json:"height"`
+	// Resolution 打印机分辨率（dpi）
+	Resolution int `json:"resolution"`
+	// OffsetX、OffsetY 打印位置偏移（点）
+	OffsetX int `json:"offset_x"`
+	OffsetY int `json:"offset_y"`
+	// Speed 打印速度，0 表示使用打印机设置
+	Speed int `json:"speed"`
+	// Darkness 打印浓度，取值范围取决于指令语言（SBPL 1-5，ZPL 0-30，TSPL 0-15），0 表示使用打印机设置
+	Darkness int `json:"darkness"`
+	// Margin 图像四周的留白（毫米）
+	Margin float64 `json:"margin"`
+	// Rotate 图像顺时针旋转的角度（0、90、180、270），与任务的 orientation-requested 叠加
+	Rotate int `json:"rotate"`
+	// Dither 图像转换为单色的方法：threshold（默认）、floyd-steinberg、ordered
+	Dither string `json:"dither"`
+	// Threshold threshold 方法的黑白分界亮度（1-255），0 表示 128
+	Threshold int `json:"threshold"`
+}
+
+// withDefaults 返回未设置的字段使用默认值后的标签设置，默认为 203 dpi 的 4×6 英寸标签
+func (l LabelConfig) withDefaults() LabelConfig {
+	if l.Width <= 0 || l.Height <= 0 {
+		l.Width, l.Height = 101.6, 152.4
+	}
+	if l.Resolution <= 0 {
+		l.Resolution = 203
+	}
+	return l
+}
+
+// dots 将毫米换算为点
+func (l LabelConfig) dots(mm float64) int {
+	return int(mm*float64(l.Resolution)/25.4 + 0.5)
+}
+
+// TextConfig 纯文本文档的排版设置
+type TextConfig struct {
+	// Fonts 字体文件（TTF/OTF/TTC），字符按顺序使用第一个包含它的字体；
+	// 为空时使用内置的等宽字体，中文使用系统中找到的中文字体
+	Fonts []string `json:"fonts"`
+	// FontSize 字号（磅），0 表示 10
+	FontSize float64 `json:"font_size"`
+	// LineSpacing 行距相对字号的倍数，0 表示 1.2
+	LineSpacing float64 `json:"line_spacing"`
+	// Margin 页边距（毫米），0 时纸张使用 10 毫米，标签不另外留白（仍使用 label.margin）
+	Margin float64 `json:"margin"`
+	// TabWidth 制表位间隔的字符数，0 表示 8
+	TabWidth int `json:"tab_width"`
+	// NoWrap 为 true 时截断超出行宽的部分，否则自动换行
+	NoWrap bool `json:"no_wrap"`
+}
+
+// backendPrinters 返回使用指定后端的打印机配置
+func (c Config) backendPrinters(backend string) map[string]PrinterConfig {
+	printers := make(map[string]PrinterConfig)
+	for name, printer := range c.Printers {
+		if printer.Backend == backend {
+			printers[name] = printer
+		}
+	}
+	return printers
+}
+
+// labelPrinters 返回使用标签打印机后端（SBPL、ZPL、TSPL）的打印机配置
+func (c Config) labelPrinters() map[string]PrinterConfig {
+	printers := make(map[string]PrinterConfig)
+	for _, backend := range []string{BackendSBPL, BackendZPL, BackendTSPL} {
+		for name, printer := range c.backendPrinters(backend) {
+			printers[name] = printer
+		}
+	}
+	return printers
+}
+
+// RetentionConfig 任务保留策略
+type RetentionConfig struct {
+	// KeepDocuments 为 true 时任务结束后仍保留文档数据，直到任务记录被清除
+	KeepDocuments bool `json:"keep_documents"`
+	// Days 已结束任务记录的保留天数，0 表示不按时间清除
+	Days int `json:"days"`
+	// MaxJobs 最多保留的已结束任务数，0 表示不按数量清除
+	MaxJobs int `json:"max_jobs"`
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() Config {
+	return Config{
+		Port:       8082,
+		SpoolDir:   filepath.Join(defaultConfigDir(), "spool"),
+		DataDir:    defaultConfigDir(),
+		MaxJobSize: 200 << 20, // 200 MB
+		Retention: RetentionConfig{
+			Days:    30,
+			MaxJobs: 500,
+		},
+		Queue: QueueConfig{
+			Concurrency: 1,
+			Capacity:    100,
+		},
+		Retry: RetryConfig{
+			MaxAttempts:  3,
+			InitialDelay: 5,
+			MaxDelay:     60,
+		},
+	}
+}
+
+// defaultConfigDir 返回用户配置目录下的 airprint-service 目录
+func defaultConfigDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = "."
+	}
+	return filepath.Join(dir, "airprint-service")
+}
+
+// DefaultConfigPath 返回默认配置文件路径（用户配置目录下的 airprint-service/config.json）
+func DefaultConfigPath() string {
+	return filepath.Join(defaultConfigDir(), "config.json")
+}
+
+// StatePath 返回状态文件路径
+func (c Config) StatePath() string {
+	return filepath.Join(c.DataDir, "state.json")
+}
+
+// JobHistoryPath 返回任务历史文件路径
+func (c Config) JobHistoryPath() string {
+	return filepath.Join(c.DataDir, "jobs.jsonl")
+}
+
+// LoadConfig 读取配置文件，文件中未出现的字段保留默认值；文件不存在时返回默认配置
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("failed to read config %s: %v", path, err)
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return DefaultConfig(), fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return DefaultConfig(), fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// validate 检查配置中无法按预期工作的取值
+func (c Config) validate() error {
+	if err := c.Retry.validate(); err != nil {
+		return err
+	}
+	if c.Queue.Concurrency < 0 || c.Queue.Capacity < 0 {
+		return fmt.Errorf("queue settings must not be negative")
+	}
+	for name, printer := range c.Printers {
+		if printer.Retry != nil {
+			if err := printer.Retry.validate(); err != nil {
+				return fmt.Errorf("printer %s: %v", name, err)
+			}
+		}
+		if q := printer.Queue; (q.Concurrency != nil && *q.Concurrency < 0) || (q.Capacity != nil && *q.Capacity < 0) {
+			return fmt.Errorf("printer %s: queue settings must not be negative", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"airprint-service/ipp"
+)
+
+// cupsTimeout 单个 CUPS 请求的超时时间
+const cupsTimeout = 10 * time.Second
+
+// cupsSockets 各系统上 CUPS 调度程序的默认 Unix 域套接字
+var cupsSockets = []string{
+	"/run/cups/cups.sock",
+	"/var/run/cups/cups.sock",
+	"/private/var/run/cupsd",
+}
+
+// cupsPrinterAttributes CUPS-Get-Printers 请求的打印机属性
+var cupsPrinterAttributes = []string{
+	"printer-name",
+	"printer-info",
+	"printer-location",
+	"printer-make-and-model",
+	"printer-state",
+	"printer-state-reasons",
+	"printer-is-accepting-jobs",
+}
+
+// cupsCapabilityAttributes 查询打印机能力时请求的打印机属性
+var cupsCapabilityAttributes = []string{
+	"printer-make-and-model",
+	"color-supported",
+	"sides-supported",
+	"printer-resolution-supported",
+	"printer-resolution-default",
+	"media-supported",
+	"media-default",
+	"document-format-supported",
+}
+
+// CUPSManager macOS/Linux CUPS 打印机管理器
+//
+// 通过 IPP 查询本机 CUPS 调度程序，结果与系统语言无关。
+type CUPSManager struct {
+	client *ipp.Client
+}
+
+// NewCUPSManager 创建连接本机 CUPS 调度程序的打印机管理器
+//
+// 设置了 CUPS_SERVER 环境变量时连接该服务器（以 "/" 开头时为 Unix 域套接字），
+// 否则优先使用默认的 Unix 域套接字，都不存在时连接 localhost:631。
+func NewCUPSManager() *CUPSManager {
+	return &CUPSManager{client: cupsClient()}
+}
+
+// cupsClient 创建连接 CUPS 调度程序的 IPP 客户端
+func cupsClient() *ipp.Client {
+	server := os.Getenv("CUPS_SERVER")
+	if server == "" {
+		for _, socket := range cupsSockets {
+			if _, err := os.Stat(socket); err == nil {
+				return ipp.NewUnixClient(socket)
+			}
+		}
+		server = "localhost:631"
+	}
+	if strings.HasPrefix(server, "/") {
+		return ipp.NewUnixClient(server)
+	}
+	if !strings.Contains(server, ":") {
+		server += ":631"
+	}
+	return &ipp.Client{URL: "http://" + server + "/"}
+}
+
+// request 发送请求，IPP 状态码不是成功时返回错误
+func (c *CUPSManager) request(path string, req *ipp.Message) (*ipp.Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cupsTimeout)
+	defer cancel()
+
+	resp, err := c.client.Do(ctx, path, req)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Status().IsSuccess() {
+		return resp, fmt.Errorf("cups returned status %s for %s", resp.Status(), ipp.Op(req.Code))
+	}
+	return resp, nil
+}
+
+// GetPrinters 获取所有打印机（CUPS-Get-Printers）
+func (c *CUPSManager) GetPrinters() ([]PrinterInfo, error) {
+	// 获取默认打印机
+	defaultPrinter, _ := c.GetDefault()
+
+	req := c.client.NewRequest(ipp.OpCUPSGetPrinters)
+	req.Operation().Add(ipp.StringAttr("requested-attributes", ipp.TagKeyword, cupsPrinterAttributes...))
+	resp, err := c.request("/", req)
+	if err != nil {
+		// 没有任何打印机时 CUPS 返回 client-error-not-found
+		if resp != nil && resp.Status() == ipp.StatusErrorNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get printers from cups: %v", err)
+	}
+
+	var printers []PrinterInfo
+	for _, group := range resp.GroupsOf(ipp.TagPrinterGroup) {
+		printer := cupsPrinterInfo(group)
+		if printer.Name == "" {
+			continue
+		}
+		printer.IsDefault = printer.Name == defaultPrinter
+		printers = append(printers, printer)
+	}
+
+	return printers, nil
+}
+
+// cupsPrinterInfo 由 CUPS 返回的打印机属性组生成打印机信息
+func cupsPrinterInfo(group *ipp.Group) PrinterInfo {
+	str := func(name string) string {
+		if attr, ok := group.Get(name); ok {
+			s, _ := attr.Str()
+			return s
+		}
+		return ""
+	}
+
+	printer := PrinterInfo{
+		Name:         str("printer-name"),
+		Description:  str("printer-info"),
+		Location:     str("printer-location"),
+		MakeAndModel: str("printer-make-and-model"),
+		Status:       "Available",
+	}
+	if printer.Description == "" {
+		printer.Description = printer.Name
+	}
+	if attr, ok := group.Get("printer-state-reasons"); ok {
+		for _, reason := range attr.Strings() {
+			if reason != "none" {
+				printer.StateReasons = append(printer.StateReasons, reason)
+			}
+		}
+	}
+
+	state := ipp.PrinterIdle
+	if attr, ok := group.Get("printer-state"); ok {
+		if v, ok := attr.Int(); ok {
+			state = ipp.PrinterState(v)
+		}
+	}
+	accepting := true
+	if attr, ok := group.Get("printer-is-accepting-jobs"); ok {
+		accepting, _ = attr.Bool()
+	}
+
+	switch {
+	case state == ipp.PrinterStopped:
+		printer.Status = "Stopped"
+	case !accepting:
+		printer.Status = "Rejecting"
+	case hasReasonPrefix(printer.StateReasons, "offline"):
+		printer.Status = "Offline"
+	case state == ipp.PrinterProcessing:
+		printer.Status = "Printing"
+	}
+	return printer
+}
+
+// hasReasonPrefix 判断 printer-state-reasons 中是否有以 prefix 开头的关键字（忽略 -report 等后缀）
+func hasReasonPrefix(reasons []string, prefix string) bool {
+	for _, reason := range reasons {
+		if strings.HasPrefix(reason, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDefault 获取默认打印机（CUPS-Get-Default）
+func (c *CUPSManager) GetDefault() (string, error) {
+	req := c.client.NewRequest(ipp.OpCUPSGetDefault)
+	req.Operation().Add(ipp.StringAttr("requested-attributes", ipp.TagKeyword, "printer-name"))
+	resp, err := c.request("/", req)
+	if err != nil {
+		if resp != nil && resp.Status() == ipp.StatusErrorNotFound {
+			return "", fmt.Errorf("no default printer set")
+		}
+		return "", fmt.Errorf("failed to get default printer from cups: %v", err)
+	}
+
+	if group := resp.Group(ipp.TagPrinterGroup); group != nil {
+		if attr, ok := group.Get("printer-name"); ok {
+			if name, ok := attr.Str(); ok && name != "" {
+				return name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no default printer set")
+}
+
+// SetDefault 设置默认打印机
+//
+// 通过 lpoptions 设置当前用户的默认打印机，CUPS-Set-Default 需要管理员权限。
+func (c *CUPSManager) SetDefault(name string) error {
+	cmd := exec.Command("lpoptions", "-d", name)
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("failed to set default printer: %v", err)
+	}
+	return nil
+}
+
+// Refresh 刷新打印机列表
+func (c *CUPSManager) Refresh() error {
+	_, err := c.GetPrinters()
+	return err
+}
+
+// GetCapabilities 通过 Get-Printer-Attributes 获取打印机能力
+func (c *CUPSManager) GetCapabilities(name string) (PrinterCapabilities, error) {
+	var caps PrinterCapabilities
+
+	path := "/printers/" + url.PathEscape(name)
+	req := c.client.NewRequest(ipp.OpGetPrinterAttributes)
+	req.Operation().Add(
+		ipp.StringAttr("printer-uri", ipp.TagURI, "ipp://localhost"+path),
+		ipp.StringAttr("requested-attributes", ipp.TagKeyword, cupsCapabilityAttributes...),
+	)
+	resp, err := c.request(path, req)
+	if err != nil {
+		return caps, fmt.Errorf("failed to get attributes of %s from cups: %v", name, err)
+	}
+	group := resp.Group(ipp.TagPrinterGroup)
+	if group == nil {
+		return caps, fmt.Errorf("cups returned no attributes for %s", name)
+	}
+
+	if attr, ok := group.Get("printer-make-and-model"); ok {
+		caps.MakeAndModel, _ = attr.Str()
+	}
+	if attr, ok := group.Get("color-supported"); ok {
+		caps.Color, _ = attr.Bool()
+	}
+	if attr, ok := group.Get("sides-supported"); ok {
+		for _, side := range attr.Strings() {
+			if strings.HasPrefix(side, "two-sided") {
+				caps.Duplex = true
+			}
+		}
+	}
+
+	// 默认分辨率排在第一个
+	if attr, ok := group.Get("printer-resolution-default"); ok && len(attr.Values) > 0 {
+		if res, ok := attr.Values[0].Value.(ipp.Resolution); ok && res.Units == ipp.UnitsDPI {
+			caps.Resolutions = append(caps.Resolutions, int(res.Xres))
+		}
+	}
+	if attr, ok := group.Get("printer-resolution-supported"); ok {
+		for _, v := range attr.Values {
+			res, ok := v.Value.(ipp.Resolution)
+			if !ok || res.Units != ipp.UnitsDPI || containsInt(caps.Resolutions, int(res.Xres)) {
+				continue
+			}
+			caps.Resolutions = append(caps.Resolutions, int(res.Xres))
+		}
+	}
+
+	// media-supported 中的自定义尺寸范围（custom_min_/custom_max_）不是可选的纸张
+	if attr, ok := group.Get("media-supported"); ok {
+		for _, media := range attr.Strings() {
+			if strings.HasPrefix(media, "custom_min_") || strings.HasPrefix(media, "custom_max_") {
+				continue
+			}
+			if _, _, ok := mediaSize(media); ok && !containsString(caps.Media, media) {
+				caps.Media = append(caps.Media, media)
+			}
+		}
+	}
+	if attr, ok := group.Get("media-default"); ok {
+		caps.DefaultMedia, _ = attr.Str()
+	}
+
+	// 只保留本服务能够接收的格式；CUPS 的内部格式（application/vnd.cups-*）不对客户端公开
+	known := defaultCapabilities().Formats
+	if attr, ok := group.Get("document-format-supported"); ok {
+		for _, format := range attr.Strings() {
+			if containsString(known, format) && !containsString(caps.Formats, format) {
+				caps.Formats = append(caps.Formats, format)
+			}
+		}
+	}
+
+	return caps, nil
+}
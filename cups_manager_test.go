@@ -0,0 +1,262 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"airprint-service/ipp"
+)
+
+// cupsTestServer 按操作返回预先设置的 IPP 响应的 CUPS 调度程序
+type cupsTestServer struct {
+	*httptest.Server
+	// responses 各操作的响应，响应的请求 ID 由服务器设置；未设置的操作返回 server-error-operation-not-supported
+	responses map[ipp.Op]*ipp.Message
+
+	mu       sync.Mutex
+	requests []*ipp.Message
+	paths    []string
+}
+
+// newCUPSTestServer 启动测试调度程序，返回连接它的 CUPS 打印机管理器
+func newCUPSTestServer(t *testing.T, responses map[ipp.Op]*ipp.Message) (*cupsTestServer, *CUPSManager) {
+	t.Helper()
+	s := &cupsTestServer{responses: responses}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s, &CUPSManager{client: &ipp.Client{URL: s.URL + "/"}}
+}
+
+// handle 解码请求并写出对应操作的响应
+func (s *cupsTestServer) handle(w http.ResponseWriter, r *http.Request) {
+	req, err := ipp.NewDecoder(r.Body).Decode()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.paths = append(s.paths, r.URL.EscapedPath())
+	s.mu.Unlock()
+
+	resp, ok := s.responses[req.Op()]
+	if !ok {
+		resp = ipp.NewResponse(ipp.Version20, ipp.StatusErrorOperationNotSupported, 0)
+	}
+	copied := *resp
+	copied.RequestID = req.RequestID
+	data, err := copied.Marshal()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/ipp")
+	w.Write(data)
+}
+
+// cupsResponse 返回带有若干打印机属性组的响应
+func cupsResponse(status ipp.Status, printers ...[]ipp.Attribute) *ipp.Message {
+	m := ipp.NewResponse(ipp.Version20, status, 0)
+	for _, attrs := range printers {
+		m.AddGroup(ipp.TagPrinterGroup).Add(attrs...)
+	}
+	return m
+}
+
+func TestCUPSPrinterInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		attrs   []ipp.Attribute
+		status  string
+		reasons []string
+	}{
+		{"idle", []ipp.Attribute{
+			ipp.IntAttr("printer-state", ipp.TagEnum, int(ipp.PrinterIdle)),
+			ipp.StringAttr("printer-state-reasons", ipp.TagKeyword, "none"),
+		}, "Available", nil},
+		{"no state attributes", nil, "Available", nil},
+		{"processing", []ipp.Attribute{
+			ipp.IntAttr("printer-state", ipp.TagEnum, int(ipp.PrinterProcessing)),
+		}, "Printing", nil},
+		{"stopped", []ipp.Attribute{
+			ipp.IntAttr("printer-state", ipp.TagEnum, int(ipp.PrinterStopped)),
+			ipp.StringAttr("printer-state-reasons", ipp.TagKeyword, "paused"),
+			ipp.BoolAttr("printer-is-accepting-jobs", false),
+		}, "Stopped", []string{"paused"}},
+		{"rejecting jobs", []ipp.Attribute{
+			ipp.IntAttr("printer-state", ipp.TagEnum, int(ipp.PrinterIdle)),
+			ipp.BoolAttr("printer-is-accepting-jobs", false),
+		}, "Rejecting", nil},
+		{"offline report", []ipp.Attribute{
+			ipp.IntAttr("printer-state", ipp.TagEnum, int(ipp.PrinterProcessing)),
+			ipp.StringAttr("printer-state-reasons", ipp.TagKeyword, "offline-report", "toner-low-warning"),
+		}, "Offline", []string{"offline-report", "toner-low-warning"}},
+		{"other reasons", []ipp.Attribute{
+			ipp.IntAttr("printer-state", ipp.TagEnum, int(ipp.PrinterIdle)),
+			ipp.StringAttr("printer-state-reasons", ipp.TagKeyword, "media-empty-error"),
+		}, "Available", []string{"media-empty-error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attrs := append([]ipp.Attribute{ipp.StringAttr("printer-name", ipp.TagName, "office")}, tt.attrs...)
+			printer := cupsPrinterInfo(&ipp.Group{Tag: ipp.TagPrinterGroup, Attrs: attrs})
+			if printer.Status != tt.status {
+				t.Errorf("status = %q, want %q", printer.Status, tt.status)
+			}
+			if !reflect.DeepEqual(printer.StateReasons, tt.reasons) {
+				t.Errorf("state reasons = %q, want %q", printer.StateReasons, tt.reasons)
+			}
+		})
+	}
+}
+
+func TestCUPSPrinterInfoDescription(t *testing.T) {
+	printer := cupsPrinterInfo(&ipp.Group{Tag: ipp.TagPrinterGroup, Attrs: []ipp.Attribute{
+		ipp.StringAttr("printer-name", ipp.TagName, "office"),
+		ipp.StringAttr("printer-location", ipp.TagText, "2F"),
+		ipp.StringAttr("printer-make-and-model", ipp.TagText, "Laser 100"),
+	}})
+	want := PrinterInfo{Name: "office", Description: "office", Status: "Available", Location: "2F", MakeAndModel: "Laser 100"}
+	if !reflect.DeepEqual(printer, want) {
+		t.Errorf("printer = %+v, want %+v", printer, want)
+	}
+}
+
+func TestCUPSGetPrinters(t *testing.T) {
+	_, m := newCUPSTestServer(t, map[ipp.Op]*ipp.Message{
+		ipp.OpCUPSGetDefault: cupsResponse(ipp.StatusOK, []ipp.Attribute{
+			ipp.StringAttr("printer-name", ipp.TagName, "label"),
+		}),
+		ipp.OpCUPSGetPrinters: cupsResponse(ipp.StatusOK,
+			[]ipp.Attribute{
+				ipp.StringAttr("printer-name", ipp.TagName, "office"),
+				ipp.StringAttr("printer-info", ipp.TagText, "Office Printer"),
+				ipp.IntAttr("printer-state", ipp.TagEnum, int(ipp.PrinterIdle)),
+			},
+			// 没有名称的属性组被忽略
+			[]ipp.Attribute{ipp.StringAttr("printer-info", ipp.TagText, "unnamed")},
+			[]ipp.Attribute{
+				ipp.StringAttr("printer-name", ipp.TagName, "label"),
+				ipp.IntAttr("printer-state", ipp.TagEnum, int(ipp.PrinterStopped)),
+			},
+		),
+	})
+
+	printers, err := m.GetPrinters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PrinterInfo{
+		{Name: "office", Description: "Office Printer", Status: "Available"},
+		{Name: "label", Description: "label", Status: "Stopped", IsDefault: true},
+	}
+	if !reflect.DeepEqual(printers, want) {
+		t.Errorf("printers = %+v, want %+v", printers, want)
+	}
+}
+
+func TestCUPSGetPrintersNotFound(t *testing.T) {
+	// 没有任何打印机时 CUPS 对两个请求都返回 client-error-not-found
+	_, m := newCUPSTestServer(t, map[ipp.Op]*ipp.Message{
+		ipp.OpCUPSGetDefault:  cupsResponse(ipp.StatusErrorNotFound),
+		ipp.OpCUPSGetPrinters: cupsResponse(ipp.StatusErrorNotFound),
+	})
+
+	printers, err := m.GetPrinters()
+	if err != nil || len(printers) != 0 {
+		t.Errorf("GetPrinters = %+v, %v; want no printers and no error", printers, err)
+	}
+	if _, err := m.GetDefault(); err == nil {
+		t.Error("GetDefault succeeded without a default printer")
+	}
+}
+
+func TestCUPSGetPrintersError(t *testing.T) {
+	_, m := newCUPSTestServer(t, map[ipp.Op]*ipp.Message{
+		ipp.OpCUPSGetPrinters: cupsResponse(ipp.StatusErrorInternal),
+	})
+	if _, err := m.GetPrinters(); err == nil {
+		t.Error("GetPrinters succeeded although cups returned an error")
+	}
+}
+
+func TestCUPSGetCapabilities(t *testing.T) {
+	dpi := func(x, y int32) ipp.Resolution { return ipp.Resolution{Xres: x, Yres: y, Units: ipp.UnitsDPI} }
+	tests := []struct {
+		name  string
+		attrs []ipp.Attribute
+		want  PrinterCapabilities
+	}{
+		{"no attributes", nil, PrinterCapabilities{}},
+		{"color and duplex", []ipp.Attribute{
+			ipp.StringAttr("printer-make-and-model", ipp.TagText, "Laser 100"),
+			ipp.BoolAttr("color-supported", true),
+			ipp.StringAttr("sides-supported", ipp.TagKeyword, "one-sided", "two-sided-long-edge"),
+		}, PrinterCapabilities{MakeAndModel: "Laser 100", Color: true, Duplex: true}},
+		{"simplex monochrome", []ipp.Attribute{
+			ipp.BoolAttr("color-supported", false),
+			ipp.StringAttr("sides-supported", ipp.TagKeyword, "one-sided"),
+		}, PrinterCapabilities{}},
+		{"default resolution first", []ipp.Attribute{
+			ipp.MakeAttr("printer-resolution-default", ipp.TagResolution, dpi(600, 600)),
+			ipp.MakeAttr("printer-resolution-supported", ipp.TagResolution,
+				dpi(300, 300), dpi(600, 600), dpi(1200, 600),
+				ipp.Resolution{Xres: 118, Yres: 118, Units: ipp.UnitsDPCM}),
+		}, PrinterCapabilities{Resolutions: []int{600, 300, 1200}}},
+		{"media", []ipp.Attribute{
+			ipp.StringAttr("media-supported", ipp.TagKeyword,
+				"iso_a4_210x297mm", "na_letter_8.5x11in", "iso_a4_210x297mm",
+				"custom_min_76.2x127mm", "custom_max_216x356mm", "om_unknown"),
+			ipp.StringAttr("media-default", ipp.TagKeyword, "na_letter_8.5x11in"),
+		}, PrinterCapabilities{Media: []string{"iso_a4_210x297mm", "na_letter_8.5x11in"}, DefaultMedia: "na_letter_8.5x11in"}},
+		{"formats", []ipp.Attribute{
+			ipp.StringAttr("document-format-supported", ipp.TagMimeType,
+				"application/octet-stream", "application/pdf", "application/vnd.cups-raster", "image/urf", "text/plain"),
+		}, PrinterCapabilities{Formats: []string{"application/octet-stream", "application/pdf", "image/urf", "text/plain"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, m := newCUPSTestServer(t, map[ipp.Op]*ipp.Message{
+				ipp.OpGetPrinterAttributes: cupsResponse(ipp.StatusOK, tt.attrs),
+			})
+			caps, err := m.GetCapabilities("office 2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(caps, tt.want) {
+				t.Errorf("capabilities = %+v, want %+v", caps, tt.want)
+			}
+
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			if len(server.paths) != 1 || server.paths[0] != "/printers/office%202" {
+				t.Errorf("request paths = %q, want /printers/office%%202", server.paths)
+			}
+			uri, _ := server.requests[0].Operation().Get("printer-uri")
+			if s, _ := uri.Str(); s != "ipp://localhost/printers/office%202" {
+				t.Errorf("printer-uri = %q", s)
+			}
+		})
+	}
+}
+
+func TestCUPSGetCapabilitiesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *ipp.Message
+	}{
+		{"not found", cupsResponse(ipp.StatusErrorNotFound)},
+		{"no printer group", cupsResponse(ipp.StatusOK)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, m := newCUPSTestServer(t, map[ipp.Op]*ipp.Message{ipp.OpGetPrinterAttributes: tt.resp})
+			if _, err := m.GetCapabilities("office"); err == nil {
+				t.Error("GetCapabilities succeeded")
+			}
+		})
+	}
+}
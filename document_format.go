@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"airprint-service/raster"
+)
+
+// formatSniffLen 判断文档格式时读取的文档开头字节数
+const formatSniffLen = 512
+
+// 文档格式（MIME 类型），打印机指令语言没有注册的类型，使用厂商前缀
+const (
+	formatOctetStream = "application/octet-stream"
+	formatPDF         = "application/pdf"
+	formatPostScript  = "application/postscript"
+	formatPCL         = "application/vnd.hp-pcl"
+	formatPNG         = "image/png"
+	formatJPEG        = "image/jpeg"
+	formatURF         = "image/urf"
+	formatPWGRaster   = "image/pwg-raster"
+	formatText        = "text/plain"
+	formatSBPL        = "application/vnd.sato-sbpl"
+	formatZPL         = "application/vnd.zebra-zpl"
+)
+
+// ErrDocumentFormatNotSupported 文档格式不在打印机的 document-format-supported 中
+var ErrDocumentFormatNotSupported = errors.New("document format not supported")
+
+// pjlUEL PJL 任务开头的通用退出语言序列
+var pjlUEL = []byte("\x1b%-12345X")
+
+// detectFormat 按文档开头的特征字节判断文档格式，无法识别时返回空字符串
+func detectFormat(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("%PDF-")):
+		return formatPDF
+	case bytes.HasPrefix(header, []byte("%!")), bytes.HasPrefix(header, []byte("\x04%!")):
+		return formatPostScript
+	case bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")):
+		return formatPNG
+	case bytes.HasPrefix(header, []byte{0xff, 0xd8, 0xff}):
+		return formatJPEG
+	case raster.IsURF(header):
+		return formatURF
+	case raster.IsPWG(header):
+		return formatPWGRaster
+	case bytes.HasPrefix(header, pjlUEL):
+		return detectPJLLanguage(header)
+	case isSBPL(header):
+		return formatSBPL
+	case isPCL(header):
+		return formatPCL
+	case isZPL(header):
+		return formatZPL
+	case isText(header):
+		return formatText
+	}
+	return ""
+}
+
+// detectPJLLanguage 按 PJL 的 ENTER LANGUAGE 命令判断其后的页面描述语言，默认为 PCL
+func detectPJLLanguage(header []byte) string {
+	upper := bytes.ToUpper(header)
+	i := bytes.Index(upper, []byte("ENTER LANGUAGE"))
+	if i < 0 {
+		return formatPCL
+	}
+	line := upper[i:]
+	if end := bytes.IndexByte(line, '\n'); end >= 0 {
+		line = line[:end]
+	}
+	switch {
+	case bytes.Contains(line, []byte("POSTSCRIPT")):
+		return formatPostScript
+	case bytes.Contains(line, []byte("PDF")):
+		return formatPDF
+	}
+	return formatPCL
+}
+
+// isSBPL 判断是否为 SATO SBPL 指令：可选的 STX 之后以 ESC A（开始）开头
+func isSBPL(header []byte) bool {
+	header = bytes.TrimPrefix(header, []byte{0x02})
+	return bytes.HasPrefix(header, []byte("\x1bA"))
+}
+
+// isPCL 判断是否为 PCL 指令：以复位（ESC E）或参数化命令（ESC & / ESC *）开头
+func isPCL(header []byte) bool {
+	return len(header) >= 2 && header[0] == 0x1b &&
+		(header[1] == 'E' || header[1] == '&' || header[1] == '*')
+}
+
+// isZPL 判断是否为 Zebra ZPL 指令：去掉空白后以 ^XA 开头，或以 ~ 命令开头且随后有 ^XA
+func isZPL(header []byte) bool {
+	header = bytes.TrimLeft(header, " \t\r\n")
+	if bytes.HasPrefix(header, []byte("^XA")) {
+		return true
+	}
+	return bytes.HasPrefix(header, []byte("~")) && bytes.Contains(header, []byte("^XA"))
+}
+
+// isText 判断是否为 UTF-8 纯文本：编码有效且除制表、换行、换页外没有控制字符
+//
+// header 可能在多字节字符中间截断，末尾不完整的字符不影响判断。
+func isText(header []byte) bool {
+	if len(header) == 0 {
+		return false
+	}
+	for i := 0; i < len(header); {
+		r, size := utf8.DecodeRune(header[i:])
+		if r == utf8.RuneError && size <= 1 {
+			if !utf8.FullRune(header[i:]) {
+				break
+			}
+			return false
+		}
+		if (r < 0x20 && r != '\t' && r != '\n' && r != '\r' && r != '\f') || r == 0x7f {
+			return false
+		}
+		i += size
+	}
+	return true
+}
+
+// formatSupported 判断打印机能否处理文档：客户端指定的格式须在 formats 中；
+// 指定 application/octet-stream 而打印机不接受时，识别出的格式在 formats 中也可以
+func formatSupported(doc *PrintDocument, formats []string) bool {
+	if containsString(formats, doc.Format) {
+		return true
+	}
+	return doc.Format == formatOctetStream && doc.Detected != "" && containsString(formats, doc.Detected)
+}
+
+// sniffFormat 读取文档开头判断格式，返回的 Reader 仍从文档开头读取
+func sniffFormat(r io.Reader) (string, io.Reader) {
+	br := bufio.NewReaderSize(r, formatSniffLen)
+	header, _ := br.Peek(formatSniffLen)
+	return detectFormat(header), br
+}
+
+// fileFormat 判断暂存文件的文档格式，无法识别时返回空字符串
+func fileFormat(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	format, _ := sniffFormat(f)
+	return format
+}
+
+// printerLanguageFiles 判断文档格式是否全部为 SBPL、ZPL 等标签打印机指令
+func printerLanguageFiles(formats []string) bool {
+	for _, format := range formats {
+		if format != formatSBPL && format != formatZPL {
+			return false
+		}
+	}
+	return len(formats) > 0
+}
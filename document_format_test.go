@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"pdf", "%PDF-1.7\n%\xe2\xe3\xcf\xd3\n", formatPDF},
+		{"postscript", "%!PS-Adobe-3.0\n", formatPostScript},
+		{"postscript with ctrl-d", "\x04%!PS-Adobe-3.0\n", formatPostScript},
+		{"png", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", formatPNG},
+		{"jpeg", "\xff\xd8\xff\xe0\x00\x10JFIF", formatJPEG},
+		{"urf", "UNIRAST\x00\x00\x00\x00\x01", formatURF},
+		{"pwg raster", "RaS2PwgRaster\x00", formatPWGRaster},
+		{"pjl without language", "\x1b%-12345X@PJL JOB\r\n\x1bE", formatPCL},
+		{"pjl postscript", "\x1b%-12345X@PJL\r\n@PJL ENTER LANGUAGE = POSTSCRIPT\r\n%!PS", formatPostScript},
+		{"pjl pdf lower case", "\x1b%-12345X@pjl enter language=pdf\n%PDF-1.4", formatPDF},
+		{"pjl pcl", "\x1b%-12345X@PJL ENTER LANGUAGE=PCL\r\n\x1bE", formatPCL},
+		{"pjl language on a later line only", "\x1b%-12345X@PJL SET COPIES=1\n@PJL ENTER LANGUAGE=POSTSCRIPT\n", formatPostScript},
+		{"sbpl", "\x1bA\x1bV100\x1bH200\x1bQ1\x1bZ", formatSBPL},
+		{"sbpl with stx", "\x02\x1bA\x1bQ1\x1bZ\x03", formatSBPL},
+		{"pcl reset", "\x1bE\x1b&l0O", formatPCL},
+		{"pcl parameterized", "\x1b&l26A", formatPCL},
+		{"pcl raster", "\x1b*r1A", formatPCL},
+		{"zpl", "^XA^FO50,50^FDHello^FS^XZ", formatZPL},
+		{"zpl after whitespace", "\r\n  ^XA^XZ", formatZPL},
+		{"zpl tilde command", "~SD15^XA^XZ", formatZPL},
+		{"tilde without zpl", "~ not a label", formatText},
+		{"text", "Hello, world\n\tindented\f", formatText},
+		{"text crlf", "line one\r\nline two\r\n", formatText},
+		{"utf-8 text", "打印测试\n", formatText},
+		{"text cut inside a character", "打印" + "\xe6\xb5", formatText},
+		{"invalid utf-8", "abc\xff\xfedef", ""},
+		{"control character", "abc\x01def", ""},
+		{"delete character", "abc\x7f", ""},
+		{"unknown escape", "\x1bX", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectFormat([]byte(tt.header)); got != tt.want {
+				t.Errorf("detectFormat(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSniffFormatKeepsData(t *testing.T) {
+	data := "%PDF-1.4\n" + strings.Repeat("x", 2*formatSniffLen)
+	format, r := sniffFormat(strings.NewReader(data))
+	if format != formatPDF {
+		t.Errorf("format = %q, want %q", format, formatPDF)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, []byte(data)) {
+		t.Error("sniffFormat consumed part of the document")
+	}
+}
+
+func TestEffectiveFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		detected string
+		want     string
+	}{
+		{formatOctetStream, formatPDF, formatPDF},
+		{formatOctetStream, "", formatOctetStream},
+		{formatText, formatPNG, formatText},
+		{formatPDF, "", formatPDF},
+	}
+	for _, tt := range tests {
+		doc := &PrintDocument{Format: tt.format, Detected: tt.detected}
+		if got := doc.EffectiveFormat(); got != tt.want {
+			t.Errorf("EffectiveFormat(%q, detected %q) = %q, want %q", tt.format, tt.detected, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSupported(t *testing.T) {
+	label := []string{formatURF, formatPWGRaster, formatPNG, formatJPEG, formatText, formatSBPL}
+	raw := []string{formatOctetStream, formatPCL}
+	tests := []struct {
+		name     string
+		format   string
+		detected string
+		formats  []string
+		want     bool
+	}{
+		{"listed format", formatPNG, formatPNG, label, true},
+		{"unlisted format", formatPDF, formatPDF, label, false},
+		{"explicit format wins over detection", formatPDF, formatPNG, label, false},
+		{"octet-stream with supported detection", formatOctetStream, formatURF, label, true},
+		{"octet-stream with unsupported detection", formatOctetStream, formatPDF, label, false},
+		{"octet-stream without detection", formatOctetStream, "", label, false},
+		{"octet-stream listed", formatOctetStream, "", raw, true},
+		{"unlisted format on passthrough printer", formatPDF, formatPDF, raw, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := &PrintDocument{Format: tt.format, Detected: tt.detected}
+			if got := formatSupported(doc, tt.formats); got != tt.want {
+				t.Errorf("formatSupported = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrinterLanguageFiles(t *testing.T) {
+	tests := []struct {
+		formats []string
+		want    bool
+	}{
+		{[]string{formatSBPL, formatZPL}, true},
+		{[]string{formatSBPL, formatPDF}, false},
+		{[]string{formatOctetStream}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := printerLanguageFiles(tt.formats); got != tt.want {
+			t.Errorf("printerLanguageFiles(%v) = %v, want %v", tt.formats, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+
+	"airprint-service/raster"
+)
+
+// pageDecoder 逐页解码光栅文档的解码器（raster.URFDecoder 或 raster.PWGDecoder）
+type pageDecoder interface {
+	NextPage() (*raster.Page, error)
+}
+
+// documentPages 将暂存的文档逐页解码为页面图像并依次交给 fn，供需要自行生成打印数据的后端使用
+//
+// 文档无法解码或格式不受支持时返回 PermanentError。format 为文档记录的格式，决定使用的解码器；只有 application/octet-stream 按文件内容识别。
+// URF 与 PWG Raster 文档解码一页处理一页，纯文本按 text 逐页排版，都不会同时保留整个
+// 文档的页面；PNG 与 JPEG 图像作为一页。fn 返回错误时停止解码并返回该错误。
+func documentPages(path, format string, text textPage, fn func(image.Image) error) error {
+	format = pageFormat(path, format)
+	if format == formatText {
+		return textPages(path, text, fn)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var decoder pageDecoder
+	switch format {
+	case formatURF:
+		decoder, err = raster.NewURFDecoder(r)
+	case formatPWGRaster:
+		decoder, err = raster.NewPWGDecoder(r)
+	case formatPNG, formatJPEG:
+		img, _, err := image.Decode(r)
+		if err != nil {
+			return permanentError(fmt.Errorf("failed to decode %s: %v", path, err))
+		}
+		return fn(img)
+	default:
+		return permanentError(fmt.Errorf("unsupported document %s: %s", path, format))
+	}
+	if err != nil {
+		return permanentError(fmt.Errorf("failed to decode %s: %v", path, err))
+	}
+
+	for {
+		page, err := decoder.NextPage()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return permanentError(fmt.Errorf("failed to decode %s: %v", path, err))
+		}
+		if err := fn(page.Image); err != nil {
+			return err
+		}
+	}
+}
+
+// checkDocumentPages 在连接打印机之前检查文档能否逐页解码，只读取文件头与图像尺寸
+//
+// 文档无法解码或格式不受支持时返回 PermanentError。
+func checkDocumentPages(path, format string) error {
+	format = pageFormat(path, format)
+	if format == formatText {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	switch format {
+	case formatURF:
+		_, err = raster.NewURFDecoder(r)
+	case formatPWGRaster:
+		_, err = raster.NewPWGDecoder(r)
+	case formatPNG, formatJPEG:
+		_, _, err = image.DecodeConfig(r)
+	default:
+		return permanentError(fmt.Errorf("unsupported document %s: %s", path, format))
+	}
+	if err != nil {
+		return permanentError(fmt.Errorf("failed to decode %s: %v", path, err))
+	}
+	return nil
+}
+
+// pageFormat 返回解码文档使用的格式：application/octet-stream 按文件内容识别，其余使用记录的格式
+func pageFormat(path, format string) string {
+	if format != formatOctetStream {
+		return format
+	}
+	if detected := fileFormat(path); detected != "" {
+		return detected
+	}
+	return format
+}
@@ -0,0 +1,305 @@
+package ipp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Value IPP 属性值
+//
+// 具体类型为 Integer、Boolean、String、Binary、Time、Resolution、Range、
+// TextWithLang、Collection 或 Void，与值标签的对应关系见 Decoder。
+type Value interface {
+	String() string
+}
+
+// Integer integer 或 enum 值
+type Integer int32
+
+// Boolean boolean 值
+type Boolean bool
+
+// String 字符串类值（text、name、keyword、uri、charset、mimeMediaType 等）
+type String string
+
+// Binary octetString 或未知标签的原始值
+type Binary []byte
+
+// Time dateTime 值
+type Time struct{ time.Time }
+
+// Units 分辨率单位
+type Units byte
+
+// 分辨率单位
+const (
+	UnitsDPI  Units = 3
+	UnitsDPCM Units = 4
+)
+
+// Resolution resolution 值
+type Resolution struct {
+	Xres, Yres int32
+	Units      Units
+}
+
+// Range rangeOfInteger 值
+type Range struct {
+	Lower, Upper int32
+}
+
+// TextWithLang textWithLanguage / nameWithLanguage 值
+type TextWithLang struct {
+	Lang, Text string
+}
+
+// Collection collection 值，成员属性按顺序保存
+type Collection []Attribute
+
+// Void 带外值（unknown、no-value 等），无数据
+type Void struct{}
+
+func (v Integer) String() string { return fmt.Sprintf("%d", int32(v)) }
+func (v Boolean) String() string { return fmt.Sprintf("%t", bool(v)) }
+func (v String) String() string  { return string(v) }
+func (v Binary) String() string  { return fmt.Sprintf("%x", []byte(v)) }
+func (v Time) String() string    { return v.Time.Format(time.RFC3339) }
+func (v Range) String() string   { return fmt.Sprintf("%d-%d", v.Lower, v.Upper) }
+func (v Void) String() string    { return "" }
+
+func (v Resolution) String() string {
+	unit := "dpi"
+	if v.Units == UnitsDPCM {
+		unit = "dpcm"
+	}
+	return fmt.Sprintf("%dx%d%s", v.Xres, v.Yres, unit)
+}
+
+func (v TextWithLang) String() string { return v.Text }
+
+func (v Collection) String() string {
+	parts := make([]string, 0, len(v))
+	for _, attr := range v {
+		parts = append(parts, attr.Name+"="+attr.String())
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+// Get 按名称查找集合成员
+func (v Collection) Get(name string) (Attribute, bool) {
+	for _, attr := range v {
+		if attr.Name == name {
+			return attr, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// TaggedValue 带值标签的单个属性值
+type TaggedValue struct {
+	Tag   Tag
+	Value Value
+}
+
+// Attribute IPP 属性，可包含多个值（1setOf）
+type Attribute struct {
+	Name   string
+	Values []TaggedValue
+}
+
+// MakeAttr 创建使用同一值标签的属性
+func MakeAttr(name string, tag Tag, values ...Value) Attribute {
+	attr := Attribute{Name: name}
+	for _, v := range values {
+		attr.Values = append(attr.Values, TaggedValue{Tag: tag, Value: v})
+	}
+	return attr
+}
+
+// StringAttr 创建字符串类属性
+func StringAttr(name string, tag Tag, values ...string) Attribute {
+	attr := Attribute{Name: name}
+	for _, v := range values {
+		attr.Values = append(attr.Values, TaggedValue{Tag: tag, Value: String(v)})
+	}
+	return attr
+}
+
+// IntAttr 创建 integer 或 enum 属性
+func IntAttr(name string, tag Tag, values ...int) Attribute {
+	attr := Attribute{Name: name}
+	for _, v := range values {
+		attr.Values = append(attr.Values, TaggedValue{Tag: tag, Value: Integer(v)})
+	}
+	return attr
+}
+
+// BoolAttr 创建 boolean 属性
+func BoolAttr(name string, value bool) Attribute {
+	return MakeAttr(name, TagBoolean, Boolean(value))
+}
+
+// TimeAttr 创建 dateTime 属性
+func TimeAttr(name string, value time.Time) Attribute {
+	return MakeAttr(name, TagDateTime, Time{value})
+}
+
+// NoValueAttr 创建值为 no-value 的属性
+func NoValueAttr(name string) Attribute {
+	return MakeAttr(name, TagNoValue, Void{})
+}
+
+// Tag 返回第一个值的标签
+func (a Attribute) Tag() Tag {
+	if len(a.Values) == 0 {
+		return TagNoValue
+	}
+	return a.Values[0].Tag
+}
+
+// Int 返回第一个 integer/enum 值
+func (a Attribute) Int() (int, bool) {
+	if len(a.Values) == 0 {
+		return 0, false
+	}
+	v, ok := a.Values[0].Value.(Integer)
+	return int(v), ok
+}
+
+// Bool 返回第一个 boolean 值
+func (a Attribute) Bool() (bool, bool) {
+	if len(a.Values) == 0 {
+		return false, false
+	}
+	v, ok := a.Values[0].Value.(Boolean)
+	return bool(v), ok
+}
+
+// Str 返回第一个字符串值（含 textWithLanguage 的文本部分）
+func (a Attribute) Str() (string, bool) {
+	if len(a.Values) == 0 {
+		return "", false
+	}
+	switch v := a.Values[0].Value.(type) {
+	case String:
+		return string(v), true
+	case TextWithLang:
+		return v.Text, true
+	}
+	return "", false
+}
+
+// Strings 返回所有字符串值
+func (a Attribute) Strings() []string {
+	var out []string
+	for _, tv := range a.Values {
+		switch v := tv.Value.(type) {
+		case String:
+			out = append(out, string(v))
+		case TextWithLang:
+			out = append(out, v.Text)
+		}
+	}
+	return out
+}
+
+// String 以逗号分隔返回所有值
+func (a Attribute) String() string {
+	parts := make([]string, 0, len(a.Values))
+	for _, tv := range a.Values {
+		parts = append(parts, tv.Value.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// Group 属性组
+type Group struct {
+	Tag   Tag
+	Attrs []Attribute
+}
+
+// Add 追加属性
+func (g *Group) Add(attrs ...Attribute) {
+	g.Attrs = append(g.Attrs, attrs...)
+}
+
+// Get 按名称查找属性
+func (g *Group) Get(name string) (Attribute, bool) {
+	if g == nil {
+		return Attribute{}, false
+	}
+	for _, attr := range g.Attrs {
+		if attr.Name == name {
+			return attr, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// Message IPP 请求或响应消息
+//
+// Code 在请求中为操作码，在响应中为状态码。
+type Message struct {
+	Version   Version
+	Code      uint16
+	RequestID uint32
+	Groups    []*Group
+}
+
+// NewRequest 创建请求消息，并添加 attributes-charset 与 attributes-natural-language
+func NewRequest(version Version, op Op, requestID uint32) *Message {
+	m := &Message{Version: version, Code: uint16(op), RequestID: requestID}
+	m.AddGroup(TagOperationGroup).Add(
+		StringAttr("attributes-charset", TagCharset, "utf-8"),
+		StringAttr("attributes-natural-language", TagLanguage, "en-us"),
+	)
+	return m
+}
+
+// NewResponse 创建响应消息，并添加 attributes-charset 与 attributes-natural-language
+func NewResponse(version Version, status Status, requestID uint32) *Message {
+	m := &Message{Version: version, Code: uint16(status), RequestID: requestID}
+	m.AddGroup(TagOperationGroup).Add(
+		StringAttr("attributes-charset", TagCharset, "utf-8"),
+		StringAttr("attributes-natural-language", TagLanguage, "en-us"),
+	)
+	return m
+}
+
+// Op 将消息码解释为操作码
+func (m *Message) Op() Op { return Op(m.Code) }
+
+// Status 将消息码解释为状态码
+func (m *Message) Status() Status { return Status(m.Code) }
+
+// AddGroup 追加一个属性组并返回它
+func (m *Message) AddGroup(tag Tag) *Group {
+	g := &Group{Tag: tag}
+	m.Groups = append(m.Groups, g)
+	return g
+}
+
+// Group 返回第一个指定标签的属性组，不存在时返回 nil
+func (m *Message) Group(tag Tag) *Group {
+	for _, g := range m.Groups {
+		if g.Tag == tag {
+			return g
+		}
+	}
+	return nil
+}
+
+// GroupsOf 返回所有指定标签的属性组
+func (m *Message) GroupsOf(tag Tag) []*Group {
+	var out []*Group
+	for _, g := range m.Groups {
+		if g.Tag == tag {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// Operation 返回操作属性组（不存在时返回 nil）
+func (m *Message) Operation() *Group { return m.Group(TagOperationGroup) }
@@ -0,0 +1,82 @@
+package ipp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// Client 通过 HTTP 发送 IPP 请求的客户端
+type Client struct {
+	// URL 接收 IPP 请求的 HTTP 地址，如 http://localhost:631/
+	URL string
+	// HTTPClient 发送请求使用的 HTTP 客户端，为 nil 时使用 http.DefaultClient
+	HTTPClient *http.Client
+
+	requestID uint32
+}
+
+// NewUnixClient 创建通过 Unix 域套接字连接服务器（如本机 CUPS 调度程序）的客户端
+func NewUnixClient(socket string) *Client {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &Client{
+		URL:        "http://localhost/",
+		HTTPClient: &http.Client{Transport: transport},
+	}
+}
+
+// NewRequest 创建 IPP/2.0 请求并分配请求 ID
+func (c *Client) NewRequest(op Op) *Message {
+	return NewRequest(Version20, op, atomic.AddUint32(&c.requestID, 1))
+}
+
+// Do 发送请求并解码响应，path 为附加在 URL 之后的资源路径（可以为空）
+//
+// 只在传输或解码失败时返回错误，IPP 状态码由调用方检查。
+func (c *Client) Do(ctx context.Context, path string, req *Message) (*Message, error) {
+	data, err := req.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	url := c.URL
+	if path != "" {
+		url = trimSlash(url) + path
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("ipp: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/ipp")
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("ipp: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ipp: HTTP status %s", resp.Status)
+	}
+	return NewDecoder(resp.Body).Decode()
+}
+
+// trimSlash 去掉末尾的 "/"
+func trimSlash(s string) string {
+	for len(s) > 0 && s[len(s)-1] == '/' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,287 @@
+package ipp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// MaxAttributesSize 属性部分允许的最大字节数，防止恶意请求耗尽内存
+const MaxAttributesSize = 1 << 20
+
+// maxCollectionDepth 集合嵌套的最大深度
+const maxCollectionDepth = 16
+
+// ErrAttributesTooLarge 属性部分超过 MaxAttributesSize
+var ErrAttributesTooLarge = errors.New("ipp: attribute section too large")
+
+// Decoder 从流中读取 IPP 消息
+//
+// Decoder 不做预读：Decode 返回时底层 Reader 恰好停在 end-of-attributes-tag
+// 之后，剩余数据即为文档内容。
+type Decoder struct {
+	r    io.Reader
+	read int
+}
+
+// NewDecoder 创建读取 r 的解码器
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Unmarshal 解码 data 中的消息，并返回其后剩余的文档数据
+func Unmarshal(data []byte) (*Message, []byte, error) {
+	r := bytes.NewReader(data)
+	m, err := NewDecoder(r).Decode()
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, data[len(data)-r.Len():], nil
+}
+
+// Decode 读取消息头与全部属性组，在 end-of-attributes-tag 处停止
+func (d *Decoder) Decode() (*Message, error) {
+	var hdr [8]byte
+	if err := d.readFull(hdr[:]); err != nil {
+		return nil, fmt.Errorf("ipp: read header: %w", err)
+	}
+	m := &Message{
+		Version:   Version(binary.BigEndian.Uint16(hdr[0:2])),
+		Code:      binary.BigEndian.Uint16(hdr[2:4]),
+		RequestID: binary.BigEndian.Uint32(hdr[4:8]),
+	}
+
+	var group *Group
+	var last *Attribute
+	for {
+		tag, err := d.readTag()
+		if err != nil {
+			return nil, err
+		}
+
+		if tag == TagEnd {
+			return m, nil
+		}
+		if tag.IsDelimiter() {
+			group = m.AddGroup(tag)
+			last = nil
+			continue
+		}
+		if group == nil {
+			return nil, fmt.Errorf("ipp: value tag %s before first group", tag)
+		}
+
+		name, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.readValue(tag, 0)
+		if err != nil {
+			return nil, fmt.Errorf("ipp: attribute %q: %w", name, err)
+		}
+
+		if name == "" {
+			// 名称长度为 0：上一个属性的附加值
+			if last == nil {
+				return nil, fmt.Errorf("ipp: additional value without attribute")
+			}
+			last.Values = append(last.Values, TaggedValue{Tag: tag, Value: value})
+			continue
+		}
+		group.Attrs = append(group.Attrs, Attribute{Name: name, Values: []TaggedValue{{Tag: tag, Value: value}}})
+		last = &group.Attrs[len(group.Attrs)-1]
+	}
+}
+
+// readValue 读取值长度与值内容，并按标签解释
+func (d *Decoder) readValue(tag Tag, depth int) (Value, error) {
+	data, err := d.readBytes()
+	if err != nil {
+		return nil, err
+	}
+	if tag == TagBeginCollection {
+		return d.readCollection(depth + 1)
+	}
+	return parseValue(tag, data)
+}
+
+// readCollection 读取 begCollection 之后的成员，直到 endCollection
+func (d *Decoder) readCollection(depth int) (Collection, error) {
+	if depth > maxCollectionDepth {
+		return nil, fmt.Errorf("collection nested too deeply")
+	}
+
+	var col Collection
+	var member *Attribute
+	for {
+		tag, err := d.readTag()
+		if err != nil {
+			return nil, err
+		}
+		if tag.IsDelimiter() {
+			return nil, fmt.Errorf("unterminated collection")
+		}
+		// 集合内各字段的名称长度必须为 0
+		if name, err := d.readString(); err != nil {
+			return nil, err
+		} else if name != "" {
+			return nil, fmt.Errorf("named value %q inside collection", name)
+		}
+
+		switch tag {
+		case TagEndCollection:
+			if _, err := d.readBytes(); err != nil {
+				return nil, err
+			}
+			return col, nil
+		case TagMemberName:
+			memberName, err := d.readBytes()
+			if err != nil {
+				return nil, err
+			}
+			col = append(col, Attribute{Name: string(memberName)})
+			member = &col[len(col)-1]
+		default:
+			if member == nil {
+				return nil, fmt.Errorf("collection value without member name")
+			}
+			value, err := d.readValue(tag, depth)
+			if err != nil {
+				return nil, fmt.Errorf("member %q: %w", member.Name, err)
+			}
+			member.Values = append(member.Values, TaggedValue{Tag: tag, Value: value})
+		}
+	}
+}
+
+// parseValue 按值标签解释原始值
+func parseValue(tag Tag, data []byte) (Value, error) {
+	switch {
+	case tag.IsOutOfBand():
+		return Void{}, nil
+	case tag == TagInteger || tag == TagEnum:
+		if len(data) != 4 {
+			return nil, fmt.Errorf("integer value with %d bytes", len(data))
+		}
+		return Integer(int32(binary.BigEndian.Uint32(data))), nil
+	case tag == TagBoolean:
+		if len(data) != 1 {
+			return nil, fmt.Errorf("boolean value with %d bytes", len(data))
+		}
+		return Boolean(data[0] != 0), nil
+	case tag == TagDateTime:
+		if len(data) != 11 {
+			return nil, fmt.Errorf("dateTime value with %d bytes", len(data))
+		}
+		return Time{decodeDateTime(data)}, nil
+	case tag == TagResolution:
+		if len(data) != 9 {
+			return nil, fmt.Errorf("resolution value with %d bytes", len(data))
+		}
+		return Resolution{
+			Xres:  int32(binary.BigEndian.Uint32(data[0:4])),
+			Yres:  int32(binary.BigEndian.Uint32(data[4:8])),
+			Units: Units(data[8]),
+		}, nil
+	case tag == TagRange:
+		if len(data) != 8 {
+			return nil, fmt.Errorf("rangeOfInteger value with %d bytes", len(data))
+		}
+		return Range{
+			Lower: int32(binary.BigEndian.Uint32(data[0:4])),
+			Upper: int32(binary.BigEndian.Uint32(data[4:8])),
+		}, nil
+	case tag == TagTextLang || tag == TagNameLang:
+		return parseTextWithLang(data)
+	case tag == TagOctetString:
+		return Binary(append([]byte(nil), data...)), nil
+	case tag >= 0x40 && tag < 0x60:
+		return String(data), nil
+	}
+	return Binary(append([]byte(nil), data...)), nil
+}
+
+// parseTextWithLang 解析 textWithLanguage / nameWithLanguage
+func parseTextWithLang(data []byte) (Value, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("text-with-language value too short")
+	}
+	langLen := int(binary.BigEndian.Uint16(data[0:2]))
+	if 2+langLen+2 > len(data) {
+		return nil, fmt.Errorf("text-with-language language overflows value")
+	}
+	lang := string(data[2 : 2+langLen])
+	rest := data[2+langLen:]
+	textLen := int(binary.BigEndian.Uint16(rest[0:2]))
+	if 2+textLen != len(rest) {
+		return nil, fmt.Errorf("text-with-language text length mismatch")
+	}
+	return TextWithLang{Lang: lang, Text: string(rest[2:])}, nil
+}
+
+// decodeDateTime 解析 RFC 2579 DateAndTime
+func decodeDateTime(b []byte) time.Time {
+	offset := int(b[9])*3600 + int(b[10])*60
+	if b[8] == '-' {
+		offset = -offset
+	}
+	loc := time.UTC
+	if offset != 0 {
+		loc = time.FixedZone("", offset)
+	}
+	return time.Date(int(binary.BigEndian.Uint16(b[0:2])), time.Month(b[2]), int(b[3]),
+		int(b[4]), int(b[5]), int(b[6]), int(b[7])*100000000, loc)
+}
+
+// readTag 读取一个标签字节
+//
+// 扩展标签（0x7F）按普通属性读取，其值（含 4 字节扩展类型码）保留为 Binary。
+func (d *Decoder) readTag() (Tag, error) {
+	var b [1]byte
+	if err := d.readFull(b[:]); err != nil {
+		if err == io.EOF {
+			return 0, fmt.Errorf("ipp: missing end-of-attributes-tag")
+		}
+		return 0, fmt.Errorf("ipp: read tag: %w", err)
+	}
+	return Tag(b[0]), nil
+}
+
+// readString 读取带 2 字节长度前缀的名称
+func (d *Decoder) readString() (string, error) {
+	data, err := d.readBytes()
+	return string(data), err
+}
+
+// readBytes 读取带 2 字节长度前缀的数据
+func (d *Decoder) readBytes() ([]byte, error) {
+	var l [2]byte
+	if err := d.readFull(l[:]); err != nil {
+		return nil, fmt.Errorf("ipp: read length: %w", err)
+	}
+	n := int(binary.BigEndian.Uint16(l[:]))
+	if n == 0 {
+		return nil, nil
+	}
+	data := make([]byte, n)
+	if err := d.readFull(data); err != nil {
+		return nil, fmt.Errorf("ipp: read value: %w", err)
+	}
+	return data, nil
+}
+
+// readFull 精确读取 len(b) 字节并累计属性部分大小
+func (d *Decoder) readFull(b []byte) error {
+	d.read += len(b)
+	if d.read > MaxAttributesSize {
+		return ErrAttributesTooLarge
+	}
+	n, err := io.ReadFull(d.r, b)
+	if err == io.ErrUnexpectedEOF || (err == io.EOF && n > 0) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,208 @@
+package ipp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Encode 将消息编码写入 w
+func (m *Message) Encode(w io.Writer) error {
+	data, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Marshal 将消息编码为字节
+func (m *Message) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	var hdr [8]byte
+	binary.BigEndian.PutUint16(hdr[0:2], uint16(m.Version))
+	binary.BigEndian.PutUint16(hdr[2:4], m.Code)
+	binary.BigEndian.PutUint32(hdr[4:8], m.RequestID)
+	buf.Write(hdr[:])
+
+	for _, g := range m.Groups {
+		if !g.Tag.IsDelimiter() || g.Tag == TagEnd {
+			return nil, fmt.Errorf("invalid group tag %s", g.Tag)
+		}
+		buf.WriteByte(byte(g.Tag))
+		for _, attr := range g.Attrs {
+			if err := encodeAttr(&buf, attr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	buf.WriteByte(byte(TagEnd))
+	return buf.Bytes(), nil
+}
+
+// encodeAttr 编码一个属性（第一个值带名称，后续值名称长度为 0）
+func encodeAttr(buf *bytes.Buffer, attr Attribute) error {
+	if attr.Name == "" {
+		return fmt.Errorf("attribute without name")
+	}
+	if len(attr.Values) == 0 {
+		return fmt.Errorf("attribute %q has no values", attr.Name)
+	}
+	for i, tv := range attr.Values {
+		name := ""
+		if i == 0 {
+			name = attr.Name
+		}
+		if err := encodeValue(buf, name, tv); err != nil {
+			return fmt.Errorf("attribute %q: %v", attr.Name, err)
+		}
+	}
+	return nil
+}
+
+// encodeValue 编码带名称（可为空）的单个值
+func encodeValue(buf *bytes.Buffer, name string, tv TaggedValue) error {
+	if tv.Tag.IsDelimiter() {
+		return fmt.Errorf("invalid value tag %s", tv.Tag)
+	}
+
+	if col, ok := tv.Value.(Collection); ok {
+		if tv.Tag != TagBeginCollection {
+			return fmt.Errorf("collection value with tag %s", tv.Tag)
+		}
+		return encodeCollection(buf, name, col)
+	}
+
+	data, err := valueBytes(tv)
+	if err != nil {
+		return err
+	}
+	if err := writeField(buf, byte(tv.Tag), name); err != nil {
+		return err
+	}
+	return writeLength(buf, data)
+}
+
+// encodeCollection 编码 begCollection ... endCollection 结构
+func encodeCollection(buf *bytes.Buffer, name string, col Collection) error {
+	if err := writeField(buf, byte(TagBeginCollection), name); err != nil {
+		return err
+	}
+	writeLength(buf, nil)
+
+	for _, member := range col {
+		if len(member.Values) == 0 {
+			return fmt.Errorf("member %q has no values", member.Name)
+		}
+		// memberAttrName：名称为空，值为成员名称
+		writeField(buf, byte(TagMemberName), "")
+		if err := writeLength(buf, []byte(member.Name)); err != nil {
+			return err
+		}
+		for _, tv := range member.Values {
+			if err := encodeValue(buf, "", tv); err != nil {
+				return fmt.Errorf("member %q: %v", member.Name, err)
+			}
+		}
+	}
+
+	writeField(buf, byte(TagEndCollection), "")
+	return writeLength(buf, nil)
+}
+
+// valueBytes 返回值的线上编码
+func valueBytes(tv TaggedValue) ([]byte, error) {
+	switch v := tv.Value.(type) {
+	case Integer:
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(v))
+		return b[:], nil
+	case Boolean:
+		if v {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case String:
+		return []byte(v), nil
+	case Binary:
+		return []byte(v), nil
+	case Void:
+		return nil, nil
+	case Range:
+		var b [8]byte
+		binary.BigEndian.PutUint32(b[0:4], uint32(v.Lower))
+		binary.BigEndian.PutUint32(b[4:8], uint32(v.Upper))
+		return b[:], nil
+	case Resolution:
+		var b [9]byte
+		binary.BigEndian.PutUint32(b[0:4], uint32(v.Xres))
+		binary.BigEndian.PutUint32(b[4:8], uint32(v.Yres))
+		b[8] = byte(v.Units)
+		return b[:], nil
+	case Time:
+		return encodeDateTime(v.Time), nil
+	case TextWithLang:
+		if len(v.Lang) > math.MaxInt16 || len(v.Text) > math.MaxInt16 {
+			return nil, fmt.Errorf("text-with-language value too long")
+		}
+		b := make([]byte, 0, 4+len(v.Lang)+len(v.Text))
+		b = binary.BigEndian.AppendUint16(b, uint16(len(v.Lang)))
+		b = append(b, v.Lang...)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(v.Text)))
+		b = append(b, v.Text...)
+		return b, nil
+	case nil:
+		return nil, fmt.Errorf("nil value")
+	}
+	return nil, fmt.Errorf("unsupported value type %T", tv.Value)
+}
+
+// encodeDateTime 编码 RFC 2579 DateAndTime（11 字节）
+func encodeDateTime(t time.Time) []byte {
+	_, offset := t.Zone()
+	direction := byte('+')
+	if offset < 0 {
+		direction = '-'
+		offset = -offset
+	}
+	b := make([]byte, 11)
+	binary.BigEndian.PutUint16(b[0:2], uint16(t.Year()))
+	b[2] = byte(t.Month())
+	b[3] = byte(t.Day())
+	b[4] = byte(t.Hour())
+	b[5] = byte(t.Minute())
+	b[6] = byte(t.Second())
+	b[7] = byte(t.Nanosecond() / 100000000)
+	b[8] = direction
+	b[9] = byte(offset / 3600)
+	b[10] = byte(offset % 3600 / 60)
+	return b
+}
+
+// writeField 写入值标签与名称
+func writeField(buf *bytes.Buffer, tag byte, name string) error {
+	if len(name) > math.MaxInt16 {
+		return fmt.Errorf("name too long: %d bytes", len(name))
+	}
+	buf.WriteByte(tag)
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(len(name)))
+	buf.Write(b[:])
+	buf.WriteString(name)
+	return nil
+}
+
+// writeLength 写入带长度前缀的值
+func writeLength(buf *bytes.Buffer, data []byte) error {
+	if len(data) > math.MaxInt16 {
+		return fmt.Errorf("value too long: %d bytes", len(data))
+	}
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(len(data)))
+	buf.Write(b[:])
+	buf.Write(data)
+	return nil
+}
@@ -0,0 +1,237 @@
+// Package ipp 实现 IPP/1.1 与 IPP/2.x 消息的编码与解码（RFC 8010）。
+package ipp
+
+import "fmt"
+
+// Version IPP 协议版本，高字节为主版本号，低字节为次版本号
+type Version uint16
+
+// 常用协议版本
+const (
+	Version11 Version = 0x0101
+	Version20 Version = 0x0200
+)
+
+// Major 主版本号
+func (v Version) Major() int { return int(v >> 8) }
+
+// Minor 次版本号
+func (v Version) Minor() int { return int(v & 0xFF) }
+
+// String 以 "major.minor" 格式返回版本
+func (v Version) String() string { return fmt.Sprintf("%d.%d", v.Major(), v.Minor()) }
+
+// Op IPP 操作码
+type Op uint16
+
+// IPP 操作码（RFC 8011 / PWG 5100.x / CUPS 扩展）
+const (
+	OpPrintJob             Op = 0x0002
+	OpPrintURI             Op = 0x0003
+	OpValidateJob          Op = 0x0004
+	OpCreateJob            Op = 0x0005
+	OpSendDocument         Op = 0x0006
+	OpSendURI              Op = 0x0007
+	OpCancelJob            Op = 0x0008
+	OpGetJobAttributes     Op = 0x0009
+	OpGetJobs              Op = 0x000A
+	OpGetPrinterAttributes Op = 0x000B
+	OpHoldJob              Op = 0x000C
+	OpReleaseJob           Op = 0x000D
+	OpRestartJob           Op = 0x000E
+	OpPausePrinter         Op = 0x0010
+	OpResumePrinter        Op = 0x0011
+	OpPurgeJobs            Op = 0x0012
+	OpCancelMyJobs         Op = 0x0039
+	OpCloseJob             Op = 0x003B
+	OpIdentifyPrinter      Op = 0x003C
+
+	OpCUPSGetDefault  Op = 0x4001
+	OpCUPSGetPrinters Op = 0x4002
+)
+
+var opNames = map[Op]string{
+	OpPrintJob:             "Print-Job",
+	OpPrintURI:             "Print-URI",
+	OpValidateJob:          "Validate-Job",
+	OpCreateJob:            "Create-Job",
+	OpSendDocument:         "Send-Document",
+	OpSendURI:              "Send-URI",
+	OpCancelJob:            "Cancel-Job",
+	OpGetJobAttributes:     "Get-Job-Attributes",
+	OpGetJobs:              "Get-Jobs",
+	OpGetPrinterAttributes: "Get-Printer-Attributes",
+	OpHoldJob:              "Hold-Job",
+	OpReleaseJob:           "Release-Job",
+	OpRestartJob:           "Restart-Job",
+	OpPausePrinter:         "Pause-Printer",
+	OpResumePrinter:        "Resume-Printer",
+	OpPurgeJobs:            "Purge-Jobs",
+	OpCancelMyJobs:         "Cancel-My-Jobs",
+	OpCloseJob:             "Close-Job",
+	OpIdentifyPrinter:      "Identify-Printer",
+	OpCUPSGetDefault:       "CUPS-Get-Default",
+	OpCUPSGetPrinters:      "CUPS-Get-Printers",
+}
+
+// String 返回操作名称
+func (op Op) String() string {
+	if name, ok := opNames[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", uint16(op))
+}
+
+// Status IPP 状态码
+type Status uint16
+
+// IPP 状态码（RFC 8011 第 4.1.6 节）
+const (
+	StatusOK                              Status = 0x0000
+	StatusOKIgnoredOrSubstituted          Status = 0x0001
+	StatusOKConflicting                   Status = 0x0002
+	StatusErrorBadRequest                 Status = 0x0400
+	StatusErrorForbidden                  Status = 0x0401
+	StatusErrorNotAuthenticated           Status = 0x0402
+	StatusErrorNotAuthorized              Status = 0x0403
+	StatusErrorNotPossible                Status = 0x0404
+	StatusErrorTimeout                    Status = 0x0405
+	StatusErrorNotFound                   Status = 0x0406
+	StatusErrorGone                       Status = 0x0407
+	StatusErrorRequestEntityTooLarge      Status = 0x0408
+	StatusErrorRequestValueTooLong        Status = 0x0409
+	StatusErrorDocumentFormatNotSupported Status = 0x040A
+	StatusErrorAttributesOrValues         Status = 0x040B
+	StatusErrorURISchemeNotSupported      Status = 0x040C
+	StatusErrorCharsetNotSupported        Status = 0x040D
+	StatusErrorConflictingAttributes      Status = 0x040E
+	StatusErrorCompressionNotSupported    Status = 0x040F
+	StatusErrorCompressionError           Status = 0x0410
+	StatusErrorDocumentFormatError        Status = 0x0411
+	StatusErrorDocumentAccessError        Status = 0x0412
+	StatusErrorInternal                   Status = 0x0500
+	StatusErrorOperationNotSupported      Status = 0x0501
+	StatusErrorServiceUnavailable         Status = 0x0502
+	StatusErrorVersionNotSupported        Status = 0x0503
+	StatusErrorDevice                     Status = 0x0504
+	StatusErrorTemporary                  Status = 0x0505
+	StatusErrorNotAcceptingJobs           Status = 0x0506
+	StatusErrorBusy                       Status = 0x0507
+	StatusErrorJobCanceled                Status = 0x0508
+	StatusErrorMultipleJobsNotSupported   Status = 0x0509
+)
+
+// IsSuccess 判断状态码是否表示成功
+func (s Status) IsSuccess() bool { return s < 0x0100 }
+
+// String 以十六进制返回状态码
+func (s Status) String() string { return fmt.Sprintf("0x%04x", uint16(s)) }
+
+// Tag IPP 定界符或值类型标签
+type Tag byte
+
+// 定界符标签（属性组）
+const (
+	TagOperationGroup    Tag = 0x01
+	TagJobGroup          Tag = 0x02
+	TagEnd               Tag = 0x03
+	TagPrinterGroup      Tag = 0x04
+	TagUnsupportedGroup  Tag = 0x05
+	TagSubscriptionGroup Tag = 0x06
+	TagEventGroup        Tag = 0x07
+	TagResourceGroup     Tag = 0x08
+	TagDocumentGroup     Tag = 0x09
+	TagSystemGroup       Tag = 0x0A
+)
+
+// 带外值标签
+const (
+	TagUnsupportedValue Tag = 0x10
+	TagDefault          Tag = 0x11
+	TagUnknown          Tag = 0x12
+	TagNoValue          Tag = 0x13
+	TagNotSettable      Tag = 0x15
+	TagDeleteAttr       Tag = 0x16
+	TagAdminDefine      Tag = 0x17
+)
+
+// 值类型标签
+const (
+	TagInteger         Tag = 0x21
+	TagBoolean         Tag = 0x22
+	TagEnum            Tag = 0x23
+	TagOctetString     Tag = 0x30
+	TagDateTime        Tag = 0x31
+	TagResolution      Tag = 0x32
+	TagRange           Tag = 0x33
+	TagBeginCollection Tag = 0x34
+	TagTextLang        Tag = 0x35
+	TagNameLang        Tag = 0x36
+	TagEndCollection   Tag = 0x37
+	TagText            Tag = 0x41
+	TagName            Tag = 0x42
+	TagKeyword         Tag = 0x44
+	TagURI             Tag = 0x45
+	TagURIScheme       Tag = 0x46
+	TagCharset         Tag = 0x47
+	TagLanguage        Tag = 0x48
+	TagMimeType        Tag = 0x49
+	TagMemberName      Tag = 0x4A
+	TagExtension       Tag = 0x7F
+)
+
+// IsDelimiter 判断标签是否为定界符（属性组或结束标签）
+func (t Tag) IsDelimiter() bool { return t < 0x10 }
+
+// IsOutOfBand 判断标签是否为带外值
+func (t Tag) IsOutOfBand() bool { return t >= 0x10 && t < 0x20 }
+
+// String 以十六进制返回标签
+func (t Tag) String() string { return fmt.Sprintf("0x%02x", byte(t)) }
+
+// JobState 任务状态（job-state 枚举值）
+type JobState int
+
+// IPP 任务状态
+const (
+	JobPending           JobState = 3
+	JobPendingHeld       JobState = 4
+	JobProcessing        JobState = 5
+	JobProcessingStopped JobState = 6
+	JobCanceled          JobState = 7
+	JobAborted           JobState = 8
+	JobCompleted         JobState = 9
+)
+
+var jobStateNames = map[JobState]string{
+	JobPending:           "pending",
+	JobPendingHeld:       "pending-held",
+	JobProcessing:        "processing",
+	JobProcessingStopped: "processing-stopped",
+	JobCanceled:          "canceled",
+	JobAborted:           "aborted",
+	JobCompleted:         "completed",
+}
+
+// String 返回任务状态关键字
+func (s JobState) String() string {
+	if name, ok := jobStateNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("job-state(%d)", int(s))
+}
+
+// IsTerminal 判断任务是否已结束（取消、中止或完成）
+func (s JobState) IsTerminal() bool {
+	return s == JobCanceled || s == JobAborted || s == JobCompleted
+}
+
+// PrinterState 打印机状态（printer-state 枚举值）
+type PrinterState int
+
+// IPP 打印机状态
+const (
+	PrinterIdle       PrinterState = 3
+	PrinterProcessing PrinterState = 4
+	PrinterStopped    PrinterState = 5
+)
@@ -0,0 +1,211 @@
+package ipp
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testRequest 返回包含各种值类型、集合与扩展标签的请求
+func testRequest() *Message {
+	m := NewRequest(Version20, OpPrintJob, 42)
+	m.Operation().Add(
+		StringAttr("printer-uri", TagURI, "ipp://localhost:631/ipp/print"),
+		StringAttr("requesting-user-name", TagName, "user"),
+		StringAttr("job-name", TagName, "abc"),
+		StringAttr("document-format", TagMimeType, "application/pdf"),
+		StringAttr("requested-attributes", TagKeyword, "job-id", "job-state", "job-state-reasons"),
+		BoolAttr("ipp-attribute-fidelity", true),
+	)
+	m.AddGroup(TagJobGroup).Add(
+		IntAttr("copies", TagInteger, 3),
+		IntAttr("orientation-requested", TagEnum, 4),
+		MakeAttr("page-ranges", TagRange, Range{Lower: 1, Upper: 3}, Range{Lower: 7, Upper: 9}),
+		MakeAttr("printer-resolution", TagResolution, Resolution{Xres: 300, Yres: 600, Units: UnitsDPI}),
+		MakeAttr("job-message", TagTextLang, TextWithLang{Lang: "zh-cn", Text: "打印测试"}),
+		TimeAttr("job-hold-until-time", time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)),
+		MakeAttr("job-description", TagOctetString, Binary{0x00, 0x03, 0xff}),
+		NoValueAttr("job-account-id"),
+		MakeAttr("media-col", TagBeginCollection, Collection{
+			MakeAttr("media-size", TagBeginCollection, Collection{
+				IntAttr("x-dimension", TagInteger, 21000),
+				IntAttr("y-dimension", TagInteger, 29700),
+			}),
+			StringAttr("media-type", TagKeyword, "stationery"),
+		}),
+		MakeAttr("x-vendor-extension", TagExtension, Binary{0x40, 0x00, 0x00, 0x01, 'x'}),
+	)
+	return m
+}
+
+func TestRoundTrip(t *testing.T) {
+	want := testRequest()
+	data, err := want.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, rest, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 0 {
+		t.Errorf("%d bytes left after end-of-attributes-tag", len(rest))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded message differs:\ngot  %+v\nwant %+v", got, want)
+	}
+
+	again, err := got.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, data) {
+		t.Error("re-encoding the decoded message changed the bytes")
+	}
+}
+
+func TestDecodeCollectionMembers(t *testing.T) {
+	data, err := testRequest().Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attr, ok := m.Group(TagJobGroup).Get("media-col")
+	if !ok {
+		t.Fatal("media-col missing")
+	}
+	col, ok := attr.Values[0].Value.(Collection)
+	if !ok {
+		t.Fatalf("media-col value is %T, want Collection", attr.Values[0].Value)
+	}
+	size, ok := col.Get("media-size")
+	if !ok {
+		t.Fatal("media-size missing")
+	}
+	x, _ := size.Values[0].Value.(Collection).Get("x-dimension")
+	if v, _ := x.Int(); v != 21000 {
+		t.Errorf("x-dimension = %d, want 21000", v)
+	}
+}
+
+func TestDecodeStopsAtEndOfAttributes(t *testing.T) {
+	// job-name 含 0x03（end-of-attributes-tag），其后的文档数据也以 0x03 开头
+	m := NewRequest(Version11, OpPrintJob, 1)
+	m.Operation().Add(
+		StringAttr("job-name", TagName, "report\x03final"),
+		IntAttr("job-id", TagInteger, 3),
+	)
+	data, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	document := []byte("\x03\x00\x03%PDF-1.4 document body\x03")
+
+	r := bytes.NewReader(append(data, document...))
+	got, err := NewDecoder(r).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, _ := got.Operation().Get("job-name")
+	if s, _ := name.Str(); s != "report\x03final" {
+		t.Errorf("job-name = %q, want %q", s, "report\x03final")
+	}
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rest, document) {
+		t.Errorf("document = %q, want %q", rest, document)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	data, err := testRequest().Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < len(data); n++ {
+		if _, _, err := Unmarshal(data[:n]); err == nil {
+			t.Fatalf("Unmarshal of %d of %d bytes succeeded", n, len(data))
+		}
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	header := []byte{0x02, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01}
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{"value before group", []byte{0x44, 0x00, 0x01, 'a', 0x00, 0x01, 'b', 0x03}},
+		{"additional value first", []byte{0x01, 0x44, 0x00, 0x00, 0x00, 0x01, 'b', 0x03}},
+		{"short integer", []byte{0x01, 0x21, 0x00, 0x01, 'n', 0x00, 0x02, 0x00, 0x01, 0x03}},
+		{"unterminated collection", []byte{0x01, 0x34, 0x00, 0x01, 'c', 0x00, 0x00, 0x03}},
+		{"member without name", []byte{0x01, 0x34, 0x00, 0x01, 'c', 0x00, 0x00,
+			0x21, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01, 0x37, 0x00, 0x00, 0x00, 0x00, 0x03}},
+		{"missing end tag", []byte{0x01, 0x44, 0x00, 0x01, 'a', 0x00, 0x01, 'b'}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Unmarshal(append(header, tt.body...)); err == nil {
+				t.Error("Unmarshal succeeded")
+			}
+		})
+	}
+}
+
+func TestDecodeAttributesTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write([]byte{0x02, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x01, 0x01})
+	value := strings.Repeat("x", math.MaxInt16)
+	for buf.Len() <= MaxAttributesSize {
+		buf.Write([]byte{0x41, 0x00, 0x01, 'a', 0x7f, 0xff})
+		buf.WriteString(value)
+	}
+	buf.WriteByte(0x03)
+
+	if _, _, err := Unmarshal(buf.Bytes()); !errors.Is(err, ErrAttributesTooLarge) {
+		t.Errorf("Unmarshal error = %v, want ErrAttributesTooLarge", err)
+	}
+}
+
+func TestEncodeTooLong(t *testing.T) {
+	long := strings.Repeat("x", math.MaxInt16+1)
+	tests := []struct {
+		name string
+		attr Attribute
+	}{
+		{"value", StringAttr("job-name", TagName, long)},
+		{"name", StringAttr(long, TagName, "job")},
+		{"text with language", MakeAttr("job-name", TagNameLang, TextWithLang{Lang: "en", Text: long})},
+		{"collection member name", MakeAttr("media-col", TagBeginCollection, Collection{
+			StringAttr(long, TagKeyword, "a"),
+		})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewResponse(Version11, StatusOK, 1)
+			m.AddGroup(TagJobGroup).Add(tt.attr)
+			if _, err := m.Marshal(); err == nil {
+				t.Error("Marshal succeeded")
+			}
+		})
+	}
+
+	// 恰好 MaxInt16 字节的值可以编码
+	m := NewResponse(Version11, StatusOK, 1)
+	m.AddGroup(TagJobGroup).Add(StringAttr("job-name", TagName, long[:math.MaxInt16]))
+	if _, err := m.Marshal(); err != nil {
+		t.Errorf("Marshal of a %d byte value failed: %v", math.MaxInt16, err)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"airprint-service/ipp"
+)
+
+// JobRecord 持久化的任务元数据
+type JobRecord struct {
+	ID           int              `json:"id"`
+	Name         string           `json:"name"`
+	User         string           `json:"user,omitempty"`
+	Format       string           `json:"format"`
+	Size         int64            `json:"size"`
+	PrinterName  string           `json:"printer"`
+	State        ipp.JobState     `json:"state"`
+	Reasons      []string         `json:"reasons,omitempty"`
+	Error        string           `json:"error,omitempty"`
+	CreatedAt    time.Time        `json:"created_at"`
+	ProcessingAt time.Time        `json:"processing_at"`
+	CompletedAt  time.Time        `json:"completed_at"`
+	HoldUntil    string           `json:"hold_until,omitempty"`
+	Incoming     bool             `json:"incoming,omitempty"`
+	RemoteJobID  string           `json:"remote_job_id,omitempty"`
+	Template     JobTemplate      `json:"template"`
+	Documents    []*PrintDocument `json:"documents,omitempty"`
+	Deleted      bool             `json:"deleted,omitempty"` // 任务已被清除
+}
+
+// newJobRecord 生成任务的持久化记录（调用方需持有 JobStore 的锁）
+func newJobRecord(job *PrintJob) JobRecord {
+	docs := make([]*PrintDocument, len(job.Documents))
+	for i, doc := range job.Documents {
+		copied := *doc
+		docs[i] = &copied
+	}
+	return JobRecord{
+		ID:           job.ID,
+		Name:         job.Name,
+		User:         job.User,
+		Format:       job.DocumentFormat(),
+		Size:         job.Size(),
+		PrinterName:  job.PrinterName,
+		State:        job.State,
+		Reasons:      append([]string(nil), job.Reasons...),
+		Error:        job.Error,
+		CreatedAt:    job.CreatedAt,
+		ProcessingAt: job.ProcessingAt,
+		CompletedAt:  job.CompletedAt,
+		HoldUntil:    job.HoldUntil,
+		Incoming:     job.Incoming,
+		RemoteJobID:  job.RemoteJobID,
+		Template:     job.Template,
+		Documents:    docs,
+	}
+}
+
+// job 由持久化记录还原任务
+func (r JobRecord) job() *PrintJob {
+	return &PrintJob{
+		ID:           r.ID,
+		Name:         r.Name,
+		User:         r.User,
+		Documents:    r.Documents,
+		HoldUntil:    r.HoldUntil,
+		Template:     r.Template,
+		Incoming:     r.Incoming,
+		State:        r.State,
+		Reasons:      r.Reasons,
+		Error:        r.Error,
+		CreatedAt:    r.CreatedAt,
+		ProcessingAt: r.ProcessingAt,
+		CompletedAt:  r.CompletedAt,
+		PrinterName:  r.PrinterName,
+		RemoteJobID:  r.RemoteJobID,
+	}
+}
+
+// JobHistory 以 JSON Lines 追加写入的任务历史
+//
+// 每次任务变化追加一行，同一任务以最后一行为准；打开时与定期压缩为每个任务一行。
+// 任务结束与清除的记录写入后立即同步到磁盘，异常退出时不会丢失任务结果。
+type JobHistory struct {
+	path string
+	file *os.File
+}
+
+// OpenJobHistory 打开任务历史文件，返回其中按 ID 排序的任务记录与曾使用过的最大任务 ID
+func OpenJobHistory(path string) (*JobHistory, []JobRecord, int, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to create job history directory: %v", err)
+	}
+
+	records, lastID, err := readJobRecords(path)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	h := &JobHistory{path: path}
+	if err := h.compact(records, lastID); err != nil {
+		return nil, nil, 0, err
+	}
+	return h, records, lastID, nil
+}
+
+// readJobRecords 读取历史文件，同一任务保留最后一条记录
+func readJobRecords(path string) ([]JobRecord, int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to open job history %s: %v", path, err)
+	}
+	defer f.Close()
+
+	lastID := 0
+	latest := make(map[int]JobRecord)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		var record JobRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// 服务异常退出时最后一行可能不完整，跳过即可
+			log.Printf("任务历史第 %d 行无法解析，已跳过: %v", line, err)
+			continue
+		}
+		if record.ID > lastID {
+			lastID = record.ID
+		}
+		if record.Deleted {
+			delete(latest, record.ID)
+			continue
+		}
+		latest[record.ID] = record
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read job history %s: %v", path, err)
+	}
+
+	records := make([]JobRecord, 0, len(latest))
+	for _, record := range latest {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records, lastID, nil
+}
+
+// compact 将记录重写为每个任务一行，并打开文件用于后续追加
+//
+// 最大 ID 的任务已被清除时保留它的清除记录，使任务 ID 在重启后不会重复使用。
+func (h *JobHistory) compact(records []JobRecord, lastID int) error {
+	if n := len(records); lastID > 0 && (n == 0 || records[n-1].ID < lastID) {
+		records = append(records, JobRecord{ID: lastID, Deleted: true})
+	}
+
+	var data []byte
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode job %d: %v", record.ID, err)
+		}
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+
+	tmp := h.path + ".tmp"
+	if err := writeSynced(tmp, data); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write job history %s: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace job history %s: %v", h.path, err)
+	}
+
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open job history %s: %v", h.path, err)
+	}
+	if h.file != nil {
+		h.file.Close()
+	}
+	h.file = f
+	return nil
+}
+
+// writeSynced 写入文件并同步到磁盘
+func writeSynced(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Compact 将历史文件重写为给定的记录（按 ID 排序），丢弃已被覆盖的旧行
+func (h *JobHistory) Compact(records []JobRecord, lastID int) error {
+	return h.compact(records, lastID)
+}
+
+// Delete 追加任务已被清除的记录
+func (h *JobHistory) Delete(id int) error {
+	return h.Save(JobRecord{ID: id, Deleted: true})
+}
+
+// Save 追加一条任务记录，任务已结束或已被清除时同步到磁盘
+func (h *JobHistory) Save(record JobRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode job %d: %v", record.ID, err)
+	}
+	if _, err := h.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write job history: %v", err)
+	}
+	if record.Deleted || record.State.IsTerminal() {
+		if err := h.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync job history: %v", err)
+		}
+	}
+	return nil
+}
+
+// Close 关闭任务历史文件
+func (h *JobHistory) Close() error {
+	return h.file.Close()
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"airprint-service/ipp"
+)
+
+// jobURI 返回任务的 IPP URI
+func (a *AirPrintServer) jobURI(job *PrintJob) string {
+	return fmt.Sprintf("%s/jobs/%d", a.printerURI(job.PrinterName), job.ID)
+}
+
+// jobTimeAttrs 生成 time-at-xxx（Unix 秒）与 date-time-at-xxx 属性，未发生时为 no-value
+func jobTimeAttrs(event string, t time.Time) []ipp.Attribute {
+	if t.IsZero() {
+		return []ipp.Attribute{
+			ipp.NoValueAttr("time-at-" + event),
+			ipp.NoValueAttr("date-time-at-" + event),
+		}
+	}
+	return []ipp.Attribute{
+		ipp.IntAttr("time-at-"+event, ipp.TagInteger, int(t.Unix())),
+		ipp.TimeAttr("date-time-at-"+event, t),
+	}
+}
+
+// jobAttributes 生成任务的全部 IPP 属性（需在 JobStore.View/Update 回调中调用）
+func (a *AirPrintServer) jobAttributes(job *PrintJob) []ipp.Attribute {
+	user := job.User
+	if user == "" {
+		user = "anonymous"
+	}
+
+	attrs := []ipp.Attribute{
+		ipp.IntAttr("job-id", ipp.TagInteger, job.ID),
+		ipp.StringAttr("job-uri", ipp.TagURI, a.jobURI(job)),
+		ipp.StringAttr("job-printer-uri", ipp.TagURI, a.printerURI(job.PrinterName)),
+		ipp.StringAttr("job-name", ipp.TagName, job.Name),
+		ipp.StringAttr("job-originating-user-name", ipp.TagName, user),
+		ipp.IntAttr("job-state", ipp.TagEnum, int(job.State)),
+		ipp.StringAttr("job-state-reasons", ipp.TagKeyword, job.Reasons...),
+		ipp.IntAttr("job-priority", ipp.TagInteger, job.Template.priority()),
+		ipp.StringAttr("document-format", ipp.TagMimeType, job.DocumentFormat()),
+		ipp.IntAttr("number-of-documents", ipp.TagInteger, len(job.Documents)),
+		ipp.IntAttr("job-k-octets", ipp.TagInteger, int((job.Size()+1023)/1024)),
+	}
+	if detected := job.DocumentFormatDetected(); detected != "" {
+		attrs = append(attrs, ipp.StringAttr("document-format-detected", ipp.TagMimeType, detected))
+	}
+	if job.Error != "" {
+		// 中止原因或改用备用打印机等警告
+		attrs = append(attrs, ipp.StringAttr("job-state-message", ipp.TagText, job.Error))
+	}
+	attrs = append(attrs, jobTimeAttrs("creation", job.CreatedAt)...)
+	attrs = append(attrs, jobTimeAttrs("processing", job.ProcessingAt)...)
+	attrs = append(attrs, jobTimeAttrs("completed", job.CompletedAt)...)
+	return attrs
+}
+
+// requestedJobID 从 job-id 或 job-uri 操作属性中取得任务 ID
+func requestedJobID(req *ipp.Message) (int, bool) {
+	op := req.Operation()
+	if attr, ok := op.Get("job-id"); ok {
+		return attr.Int()
+	}
+	if attr, ok := op.Get("job-uri"); ok {
+		if uri, ok := attr.Str(); ok {
+			id, err := strconv.Atoi(uri[strings.LastIndex(uri, "/")+1:])
+			return id, err == nil
+		}
+	}
+	return 0, false
+}
+
+// buildGetJobAttributesResponse 构建 Get-Job-Attributes 响应
+func (a *AirPrintServer) buildGetJobAttributesResponse(req *ipp.Message, printer *sharedPrinter) *ipp.Message {
+	job, errResponse := a.lookupJob(req, printer)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	response := a.newResponse(req, ipp.StatusOK)
+	a.jobs.View(func() {
+		response.AddGroup(ipp.TagJobGroup).Add(filterRequestedAttributes(req, a.jobAttributes(job), nil)...)
+	})
+	return response
+}
+
+// buildGetJobsResponse 构建 Get-Jobs 响应，返回指定打印机的任务，支持 which-jobs、my-jobs 与 limit
+func (a *AirPrintServer) buildGetJobsResponse(req *ipp.Message, printer *sharedPrinter) *ipp.Message {
+	op := req.Operation()
+
+	whichJobs := "not-completed"
+	if attr, ok := op.Get("which-jobs"); ok {
+		whichJobs, _ = attr.Str()
+	}
+	if whichJobs != "not-completed" && whichJobs != "completed" && whichJobs != "all" {
+		response := a.buildErrorResponse(req, ipp.StatusErrorAttributesOrValues)
+		response.AddGroup(ipp.TagUnsupportedGroup).Add(ipp.StringAttr("which-jobs", ipp.TagKeyword, whichJobs))
+		return response
+	}
+
+	myJobs := false
+	if attr, ok := op.Get("my-jobs"); ok {
+		myJobs, _ = attr.Bool()
+	}
+	user := ""
+	if attr, ok := op.Get("requesting-user-name"); ok {
+		user, _ = attr.Str()
+	}
+
+	limit := 0
+	if attr, ok := op.Get("limit"); ok {
+		limit, _ = attr.Int()
+	}
+
+	jobs := a.jobs.List()
+	response := a.newResponse(req, ipp.StatusOK)
+	a.jobs.View(func() {
+		var matched []*PrintJob
+		for _, job := range jobs {
+			if job.PrinterName != printer.Name {
+				continue
+			}
+			if whichJobs == "completed" && !job.State.IsTerminal() {
+				continue
+			}
+			if whichJobs == "not-completed" && job.State.IsTerminal() {
+				continue
+			}
+			if myJobs && job.User != user {
+				continue
+			}
+			matched = append(matched, job)
+		}
+
+		// 未完成任务按提交顺序，已完成任务最新的在前
+		if whichJobs != "not-completed" {
+			sort.Slice(matched, func(i, j int) bool { return matched[i].ID > matched[j].ID })
+		}
+		if limit > 0 && len(matched) > limit {
+			matched = matched[:limit]
+		}
+
+		for _, job := range matched {
+			attrs := filterRequestedAttributes(req, a.jobAttributes(job), []string{"job-id", "job-uri"})
+			response.AddGroup(ipp.TagJobGroup).Add(attrs...)
+		}
+	})
+	return response
+}
+
+// lookupJob 查找请求中指定的任务，失败时返回对应的错误响应
+//
+// 任务必须属于请求路径对应的打印机；以 job-uri 指定任务时，
+// URI 中的打印机也必须是该打印机，否则视为任务不存在。
+func (a *AirPrintServer) lookupJob(req *ipp.Message, printer *sharedPrinter) (*PrintJob, *ipp.Message) {
+	jobID, ok := requestedJobID(req)
+	if !ok {
+		return nil, a.buildErrorResponse(req, ipp.StatusErrorBadRequest)
+	}
+
+	job, ok := a.jobs.Get(jobID)
+	if !ok {
+		return nil, a.buildErrorResponse(req, ipp.StatusErrorNotFound)
+	}
+	var printerName string
+	a.jobs.View(func() {
+		printerName = job.PrinterName
+	})
+	if printerName != printer.Name {
+		return nil, a.buildErrorResponse(req, ipp.StatusErrorNotFound)
+	}
+	if _, ok := req.Operation().Get("job-id"); !ok && a.jobURIPrinter(req) != printer {
+		return nil, a.buildErrorResponse(req, ipp.StatusErrorNotFound)
+	}
+	return job, nil
+}
+
+// jobURIPrinter 返回请求的 job-uri 所在的共享打印机，无法解析时返回 nil
+func (a *AirPrintServer) jobURIPrinter(req *ipp.Message) *sharedPrinter {
+	attr, ok := req.Operation().Get("job-uri")
+	if !ok {
+		return nil
+	}
+	uri, ok := attr.Str()
+	if !ok {
+		return nil
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil
+	}
+	i := strings.LastIndex(u.Path, "/jobs/")
+	if i < 0 {
+		return nil
+	}
+	return a.printerForPath(u.Path[:i])
+}
+
+// jobAccessAllowed 判断请求者能否操作任务：提交任务的用户本人或操作员
+func (a *AirPrintServer) jobAccessAllowed(r *http.Request, req *ipp.Message, job *PrintJob) bool {
+	var owner string
+	a.jobs.View(func() {
+		owner = job.User
+	})
+	var user string
+	if attr, ok := req.Operation().Get("requesting-user-name"); ok {
+		user, _ = attr.Str()
+	}
+	return user == owner || a.operatorAllowed(r, req)
+}
+
+// lookupOwnedJob 查找请求中指定的任务并检查请求者的操作权限
+func (a *AirPrintServer) lookupOwnedJob(r *http.Request, req *ipp.Message, printer *sharedPrinter) (*PrintJob, *ipp.Message) {
+	job, errResponse := a.lookupJob(req, printer)
+	if errResponse != nil {
+		return nil, errResponse
+	}
+	if !a.jobAccessAllowed(r, req, job) {
+		log.Printf("拒绝来自 %s 的 %s 操作，打印任务 ID: %d", r.RemoteAddr, req.Op(), job.ID)
+		return nil, a.buildErrorResponse(req, ipp.StatusErrorNotAuthorized)
+	}
+	return job, nil
+}
+
+// buildCancelJobResponse 构建 Cancel-Job 响应
+//
+// 等待中或挂起的任务直接取消；正在处理的任务会中断打印命令；
+// 已提交到系统打印队列的任务通过其队列任务 ID 一并取消。
+func (a *AirPrintServer) buildCancelJobResponse(r *http.Request, req *ipp.Message, printer *sharedPrinter) *ipp.Message {
+	job, errResponse := a.lookupOwnedJob(r, req, printer)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	var state ipp.JobState
+	var remoteID string
+	err := a.jobs.Update(job, func() error {
+		state = job.State
+		remoteID = job.RemoteJobID
+		if state == ipp.JobCompleted {
+			// 本地已完成但系统队列可能仍在打印，下面尝试取消系统任务
+			if remoteID == "" {
+				return fmt.Errorf("job %d already completed", job.ID)
+			}
+			return nil
+		}
+		if err := a.jobs.transitionLocked(job, ipp.JobCanceled); err != nil {
+			return err
+		}
+		if job.cancel != nil {
+			job.cancel()
+		}
+		return nil
+	})
+	if err != nil {
+		return a.buildErrorResponse(req, ipp.StatusErrorNotPossible)
+	}
+	a.dequeueJob(job)
+
+	if remoteID != "" {
+		if err := a.backends.CancelJob(remoteID); err != nil {
+			log.Printf("取消系统打印任务 %s 失败: %v", remoteID, err)
+			if state == ipp.JobCompleted {
+				return a.buildErrorResponse(req, ipp.StatusErrorNotPossible)
+			}
+		} else if state == ipp.JobCompleted {
+			a.jobs.Transition(job, ipp.JobCanceled)
+		}
+	}
+
+	log.Printf("打印任务 ID: %d 已取消", job.ID)
+	return a.newResponse(req, ipp.StatusOK)
+}
+
+// buildHoldJobResponse 构建 Hold-Job 响应，仅等待中的任务可以挂起
+func (a *AirPrintServer) buildHoldJobResponse(r *http.Request, req *ipp.Message, printer *sharedPrinter) *ipp.Message {
+	job, errResponse := a.lookupOwnedJob(r, req, printer)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	err := a.jobs.Update(job, func() error {
+		switch job.State {
+		case ipp.JobPending:
+			if err := a.jobs.transitionLocked(job, ipp.JobPendingHeld, "job-hold-until-specified"); err != nil {
+				return err
+			}
+		case ipp.JobPendingHeld:
+			// 仍在接收文档的任务在接收完毕后继续保持挂起
+		default:
+			return fmt.Errorf("job %d cannot be held in state %s", job.ID, job.State)
+		}
+		job.HoldUntil = "indefinite"
+		return nil
+	})
+	if err != nil {
+		return a.buildErrorResponse(req, ipp.StatusErrorNotPossible)
+	}
+
+	a.dequeueJob(job)
+
+	log.Printf("打印任务 ID: %d 已挂起", job.ID)
+	return a.newResponse(req, ipp.StatusOK)
+}
+
+// buildReleaseJobResponse 构建 Release-Job 响应，释放挂起的任务并开始执行
+func (a *AirPrintServer) buildReleaseJobResponse(r *http.Request, req *ipp.Message, printer *sharedPrinter) *ipp.Message {
+	job, errResponse := a.lookupOwnedJob(r, req, printer)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	// 入队与状态转换在同一次加锁中完成，队列已满时任务保持挂起
+	err := a.jobs.Update(job, func() error {
+		if job.State != ipp.JobPendingHeld || job.Incoming {
+			return fmt.Errorf("job %d is not held", job.ID)
+		}
+		if err := a.enqueueLocked(job, true); err != nil {
+			return err
+		}
+		job.HoldUntil = "no-hold"
+		return a.jobs.transitionLocked(job, ipp.JobPending)
+	})
+	if errors.Is(err, ErrQueueFull) {
+		return a.buildErrorResponse(req, ipp.StatusErrorBusy)
+	}
+	if err != nil {
+		return a.buildErrorResponse(req, ipp.StatusErrorNotPossible)
+	}
+
+	log.Printf("打印任务 ID: %d 已释放", job.ID)
+	return a.newResponse(req, ipp.StatusOK)
+}
+
+// buildRestartJobResponse 构建 Restart-Job 响应，重新打印已结束的任务
+func (a *AirPrintServer) buildRestartJobResponse(r *http.Request, req *ipp.Message, printer *sharedPrinter) *ipp.Message {
+	job, errResponse := a.lookupOwnedJob(r, req, printer)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	// 入队与状态转换在同一次加锁中完成，队列已满时任务保持原来的结束状态
+	err := a.jobs.Update(job, func() error {
+		if !job.State.IsTerminal() {
+			return fmt.Errorf("job %d has not finished", job.ID)
+		}
+		// 文档数据已按保留策略删除的任务无法重新打印
+		if !hasDocuments(job) {
+			return fmt.Errorf("job %d documents have been released", job.ID)
+		}
+		if err := a.enqueueLocked(job, true); err != nil {
+			return err
+		}
+		job.RemoteJobID = ""
+		job.Error = ""
+		return a.jobs.transitionLocked(job, ipp.JobPending)
+	})
+	if errors.Is(err, ErrQueueFull) {
+		return a.buildErrorResponse(req, ipp.StatusErrorBusy)
+	}
+	if err != nil {
+		return a.buildErrorResponse(req, ipp.StatusErrorNotPossible)
+	}
+
+	log.Printf("打印任务 ID: %d 已重新开始", job.ID)
+	return a.newResponse(req, ipp.StatusOK)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"airprint-service/ipp"
+)
+
+// newJobTestServer 返回共享默认打印机与 office 两台打印机的服务器，
+// 以及 alice 提交到 office 的一个任务
+func newJobTestServer() (*AirPrintServer, *PrintJob) {
+	a := &AirPrintServer{
+		printers: map[string]*sharedPrinter{
+			"":       {Name: ""},
+			"office": {Name: "office"},
+		},
+		config: Config{AdminUsers: []string{"admin"}},
+		jobs:   NewJobStore(),
+	}
+	job := &PrintJob{Name: "report", User: "alice", PrinterName: "office"}
+	a.jobs.Add(job)
+	return a, job
+}
+
+// jobRequest 返回以 job-id 或 job-uri 指定任务的 Cancel-Job 请求，user 为空时不带 requesting-user-name
+func jobRequest(jobAttr ipp.Attribute, user string) *ipp.Message {
+	req := ipp.NewRequest(ipp.Version20, ipp.OpCancelJob, 1)
+	req.Operation().Add(jobAttr)
+	if user != "" {
+		req.Operation().Add(ipp.StringAttr("requesting-user-name", ipp.TagName, user))
+	}
+	return req
+}
+
+func TestLookupOwnedJob(t *testing.T) {
+	a, job := newJobTestServer()
+	jobID := ipp.IntAttr("job-id", ipp.TagInteger, job.ID)
+	tests := []struct {
+		name    string
+		printer string
+		attr    ipp.Attribute
+		user    string
+		remote  string
+		status  ipp.Status // ipp.StatusOK 表示允许操作
+	}{
+		{"owner", "office", jobID, "alice", "192.168.1.20:5000", ipp.StatusOK},
+		{"other user", "office", jobID, "bob", "192.168.1.20:5000", ipp.StatusErrorNotAuthorized},
+		{"no user name", "office", jobID, "", "192.168.1.20:5000", ipp.StatusErrorNotAuthorized},
+		{"admin user", "office", jobID, "admin", "192.168.1.20:5000", ipp.StatusOK},
+		{"loopback client", "office", jobID, "bob", "127.0.0.1:5000", ipp.StatusOK},
+		{"job on another printer", "", jobID, "alice", "192.168.1.20:5000", ipp.StatusErrorNotFound},
+		{"unknown job", "office", ipp.IntAttr("job-id", ipp.TagInteger, job.ID+1), "alice", "192.168.1.20:5000",
+			ipp.StatusErrorNotFound},
+		{"job uri", "office", ipp.StringAttr("job-uri", ipp.TagURI, "ipp://host:631/ipp/print/office/jobs/1"),
+			"alice", "192.168.1.20:5000", ipp.StatusOK},
+		{"job uri on another printer", "office", ipp.StringAttr("job-uri", ipp.TagURI, "ipp://host:631/ipp/print/jobs/1"),
+			"alice", "192.168.1.20:5000", ipp.StatusErrorNotFound},
+		{"job uri without jobs path", "office", ipp.StringAttr("job-uri", ipp.TagURI, "ipp://host:631/1"),
+			"alice", "192.168.1.20:5000", ipp.StatusErrorNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/ipp/print", nil)
+			r.RemoteAddr = tt.remote
+			got, errResponse := a.lookupOwnedJob(r, jobRequest(tt.attr, tt.user), a.printers[tt.printer])
+			status := ipp.StatusOK
+			if errResponse != nil {
+				status = errResponse.Status()
+			}
+			if status != tt.status {
+				t.Fatalf("status = %v, want %v", status, tt.status)
+			}
+			if status == ipp.StatusOK && got != job {
+				t.Errorf("job = %+v, want %+v", got, job)
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"airprint-service/ipp"
+)
+
+// jobTransitions 允许的任务状态转换（RFC 8011 5.3.7）
+//
+// 已结束的任务只能通过 Restart-Job 回到 pending；已完成的任务在取消系统打印队列中的
+// 任务后可以转为 canceled。
+var jobTransitions = map[ipp.JobState][]ipp.JobState{
+	ipp.JobPending:           {ipp.JobPendingHeld, ipp.JobProcessing, ipp.JobCanceled, ipp.JobAborted},
+	ipp.JobPendingHeld:       {ipp.JobPending, ipp.JobCanceled, ipp.JobAborted},
+	ipp.JobProcessing:        {ipp.JobProcessingStopped, ipp.JobCompleted, ipp.JobCanceled, ipp.JobAborted},
+	ipp.JobProcessingStopped: {ipp.JobProcessing, ipp.JobCanceled, ipp.JobAborted},
+	ipp.JobCompleted:         {ipp.JobPending, ipp.JobCanceled},
+	ipp.JobCanceled:          {ipp.JobPending},
+	ipp.JobAborted:           {ipp.JobPending},
+}
+
+// JobEvent 任务变化通知
+type JobEvent struct {
+	JobID       int
+	Name        string
+	PrinterName string
+	State       ipp.JobState
+	Previous    ipp.JobState
+	Reasons     []string
+	Removed     bool // 任务已从存储中清除
+}
+
+// JobStore 并发安全的任务存储
+//
+// 任务字段只能在 View/Update 回调中访问；状态只能通过 Transition 或
+// Update 回调中的 transitionLocked 修改，每次变化都会通知订阅者。
+type JobStore struct {
+	mu          sync.Mutex
+	jobs        map[int]*PrintJob
+	lastID      int
+	subscribers map[chan JobEvent]struct{}
+	history     *JobHistory
+}
+
+// NewJobStore 创建空的任务存储
+func NewJobStore() *JobStore {
+	return &JobStore{
+		jobs:        make(map[int]*PrintJob),
+		subscribers: make(map[chan JobEvent]struct{}),
+	}
+}
+
+// Add 为任务分配 ID 并保存
+func (s *JobStore) Add(job *PrintJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	job.ID = s.lastID
+	job.CreatedAt = time.Now()
+	if job.State == 0 {
+		job.State = ipp.JobPending
+	}
+	job.Reasons = defaultJobStateReasons(job)
+	s.jobs[job.ID] = job
+	s.changedLocked(job, job.State)
+}
+
+// Open 打开任务历史，恢复历史任务并接着最大的任务 ID 继续编号
+//
+// 服务停止时仍在等待或处理中的任务重新排队，返回值为需要重新执行的任务；
+// 仍在接收文档或文档已丢失的任务无法继续，标记为中止。重复调用时不做任何事。
+func (s *JobStore) Open(path string) ([]*PrintJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.history != nil {
+		return nil, nil
+	}
+	history, records, lastID, err := OpenJobHistory(path)
+	if err != nil {
+		return nil, err
+	}
+	s.history = history
+	if lastID > s.lastID {
+		s.lastID = lastID
+	}
+
+	var requeue []*PrintJob
+	for _, record := range records {
+		job := record.job()
+		if job.ID > s.lastID {
+			s.lastID = job.ID
+		}
+		if _, exists := s.jobs[job.ID]; exists {
+			continue
+		}
+		s.jobs[job.ID] = job
+
+		// 恢复属于重建任务，不经过 transitionLocked 的状态转换校验
+		switch {
+		case job.State == ipp.JobPendingHeld && job.Incoming:
+			job.Incoming = false
+			job.State = ipp.JobAborted
+			job.Error = "service stopped while receiving documents"
+			job.CompletedAt = time.Now()
+		case job.State == ipp.JobPending || job.State == ipp.JobProcessing || job.State == ipp.JobProcessingStopped:
+			if missing := missingDocument(job); missing != "" {
+				job.State = ipp.JobAborted
+				job.Error = "document data lost: " + missing
+				job.CompletedAt = time.Now()
+				break
+			}
+			job.State = ipp.JobPending
+			job.ProcessingAt = time.Time{}
+			job.RemoteJobID = ""
+			requeue = append(requeue, job)
+		default:
+			continue
+		}
+		job.Reasons = defaultJobStateReasons(job)
+		s.persistLocked(job)
+	}
+	return requeue, nil
+}
+
+// missingDocument 返回任务中第一个已不存在的暂存文件
+func missingDocument(job *PrintJob) string {
+	for _, doc := range job.Documents {
+		if _, err := os.Stat(doc.Path); err != nil {
+			return doc.Path
+		}
+	}
+	return ""
+}
+
+// Get 按 ID 查找任务
+func (s *JobStore) Get(id int) (*PrintJob, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+// List 返回按 ID 排序的全部任务
+func (s *JobStore) List() []*PrintJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := make([]*PrintJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+	return jobs
+}
+
+// Remove 从存储与任务历史中清除任务
+func (s *JobStore) Remove(job *PrintJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[job.ID]; !ok {
+		return
+	}
+	delete(s.jobs, job.ID)
+	if s.history != nil {
+		if err := s.history.Delete(job.ID); err != nil {
+			log.Printf("清除任务 %d 的历史记录失败: %v", job.ID, err)
+		}
+	}
+
+	event := s.eventLocked(job, job.State)
+	event.Removed = true
+	s.sendLocked(event)
+}
+
+// CompactHistory 将任务历史重写为当前每个任务一行，避免历史文件随任务变化无限增长
+func (s *JobStore) CompactHistory() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.history == nil {
+		return nil
+	}
+	records := make([]JobRecord, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		records = append(records, newJobRecord(job))
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return s.history.Compact(records, s.lastID)
+}
+
+// View 在持有锁的情况下读取任务字段
+func (s *JobStore) View(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn()
+}
+
+// Update 在持有锁的情况下修改任务，fn 返回 nil 时通知订阅者
+func (s *JobStore) Update(job *PrintJob, fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := job.State
+	if err := fn(); err != nil {
+		return err
+	}
+	s.changedLocked(job, previous)
+	return nil
+}
+
+// Transition 将任务转换到 state，reasons 为空时使用该状态的默认 job-state-reasons
+func (s *JobStore) Transition(job *PrintJob, state ipp.JobState, reasons ...string) error {
+	return s.Update(job, func() error {
+		return s.transitionLocked(job, state, reasons...)
+	})
+}
+
+// transitionLocked 校验并执行状态转换，同时记录处理/完成时间（调用方需持有 mu）
+func (s *JobStore) transitionLocked(job *PrintJob, state ipp.JobState, reasons ...string) error {
+	if !canTransition(job.State, state) {
+		return fmt.Errorf("invalid job state transition %s -> %s", job.State, state)
+	}
+
+	job.State = state
+	switch {
+	case state == ipp.JobPending:
+		// Restart-Job 重新开始时清除上一次执行的记录
+		job.ProcessingAt = time.Time{}
+		job.CompletedAt = time.Time{}
+	case state == ipp.JobProcessing && job.ProcessingAt.IsZero():
+		job.ProcessingAt = time.Now()
+	case state.IsTerminal():
+		job.CompletedAt = time.Now()
+	}
+
+	if len(reasons) > 0 {
+		job.Reasons = reasons
+	} else {
+		job.Reasons = defaultJobStateReasons(job)
+	}
+	return nil
+}
+
+// canTransition 判断任务状态转换是否合法
+func canTransition(from, to ipp.JobState) bool {
+	for _, s := range jobTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribe 订阅任务变化，返回通知通道与取消订阅函数
+//
+// 通知不会阻塞任务处理，订阅者处理不及时时多余的通知会被丢弃。
+func (s *JobStore) Subscribe() (<-chan JobEvent, func()) {
+	ch := make(chan JobEvent, 32)
+
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subscribers, ch)
+			close(ch)
+			s.mu.Unlock()
+		})
+	}
+}
+
+// changedLocked 保存任务记录并通知订阅者（调用方需持有 mu）
+func (s *JobStore) changedLocked(job *PrintJob, previous ipp.JobState) {
+	s.persistLocked(job)
+	s.notifyLocked(job, previous)
+}
+
+// persistLocked 将任务写入任务历史（调用方需持有 mu）
+func (s *JobStore) persistLocked(job *PrintJob) {
+	if s.history == nil {
+		return
+	}
+	if err := s.history.Save(newJobRecord(job)); err != nil {
+		log.Printf("保存任务 %d 的历史记录失败: %v", job.ID, err)
+	}
+}
+
+// notifyLocked 向全部订阅者发送任务变化（调用方需持有 mu）
+func (s *JobStore) notifyLocked(job *PrintJob, previous ipp.JobState) {
+	s.sendLocked(s.eventLocked(job, previous))
+}
+
+// eventLocked 生成任务的变化通知（调用方需持有 mu）
+func (s *JobStore) eventLocked(job *PrintJob, previous ipp.JobState) JobEvent {
+	return JobEvent{
+		JobID:       job.ID,
+		Name:        job.Name,
+		PrinterName: job.PrinterName,
+		State:       job.State,
+		Previous:    previous,
+		Reasons:     append([]string(nil), job.Reasons...),
+	}
+}
+
+// sendLocked 向全部订阅者发送通知（调用方需持有 mu）
+func (s *JobStore) sendLocked(event JobEvent) {
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// defaultJobStateReasons 返回任务当前状态对应的默认 job-state-reasons
+func defaultJobStateReasons(job *PrintJob) []string {
+	switch job.State {
+	case ipp.JobPendingHeld:
+		if job.Incoming {
+			return []string{"job-incoming"}
+		}
+		return []string{"job-hold-until-specified"}
+	case ipp.JobProcessing:
+		return []string{"job-printing"}
+	case ipp.JobProcessingStopped:
+		return []string{"printer-stopped"}
+	case ipp.JobCompleted:
+		return []string{"job-completed-successfully"}
+	case ipp.JobAborted:
+		return []string{"aborted-by-system"}
+	case ipp.JobCanceled:
+		return []string{"job-canceled-by-user"}
+	}
+	return []string{"none"}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"airprint-service/ipp"
+)
+
+// JobTemplate 任务模板属性，即用户在打印对话框中选择的选项
+//
+// 零值字段表示客户端未指定，由打印机使用默认值。
+type JobTemplate struct {
+	Copies       int         `json:"copies,omitempty"`
+	Sides        string      `json:"sides,omitempty"`
+	Media        string      `json:"media,omitempty"`                 // PWG 5101.1 媒体名称，如 iso_a4_210x297mm
+	Orientation  int         `json:"orientation_requested,omitempty"` // orientation-requested 枚举值
+	PageRanges   []ipp.Range `json:"page_ranges,omitempty"`
+	PrintQuality int         `json:"print_quality,omitempty"` // print-quality 枚举值：3 草稿、4 标准、5 高质量
+	ColorMode    string      `json:"print_color_mode,omitempty"`
+	Priority     int         `json:"job_priority,omitempty"` // job-priority：1 最低、100 最高
+}
+
+// defaultJobPriority 未指定 job-priority 时的优先级
+const defaultJobPriority = 50
+
+// priority 返回任务优先级，未指定时为默认值
+func (t JobTemplate) priority() int {
+	if t.Priority == 0 {
+		return defaultJobPriority
+	}
+	return t.Priority
+}
+
+// TemplateSupport 打印机支持的任务模板取值
+type TemplateSupport struct {
+	MaxCopies      int
+	Sides          []string
+	Media          []string
+	DefaultMedia   string
+	Orientations   []int
+	PrintQualities []int
+	ColorModes     []string
+}
+
+// printerAttributes 生成任务模板相关的打印机属性（xxx-supported / xxx-default）
+func (s TemplateSupport) printerAttributes() []ipp.Attribute {
+	return []ipp.Attribute{
+		ipp.MakeAttr("copies-supported", ipp.TagRange, ipp.Range{Lower: 1, Upper: int32(s.MaxCopies)}),
+		ipp.IntAttr("copies-default", ipp.TagInteger, 1),
+		ipp.StringAttr("sides-supported", ipp.TagKeyword, s.Sides...),
+		ipp.StringAttr("sides-default", ipp.TagKeyword, "one-sided"),
+		ipp.StringAttr("media-supported", ipp.TagKeyword, s.Media...),
+		ipp.StringAttr("media-default", ipp.TagKeyword, s.DefaultMedia),
+		ipp.StringAttr("media-col-supported", ipp.TagKeyword, "media-size", "media-source", "media-type"),
+		ipp.IntAttr("orientation-requested-supported", ipp.TagEnum, s.Orientations...),
+		ipp.IntAttr("orientation-requested-default", ipp.TagEnum, 3),
+		ipp.BoolAttr("page-ranges-supported", true),
+		ipp.IntAttr("print-quality-supported", ipp.TagEnum, s.PrintQualities...),
+		ipp.IntAttr("print-quality-default", ipp.TagEnum, 4),
+		ipp.StringAttr("print-color-mode-supported", ipp.TagKeyword, s.ColorModes...),
+		ipp.StringAttr("print-color-mode-default", ipp.TagKeyword, s.ColorModes[0]),
+		ipp.IntAttr("job-priority-supported", ipp.TagInteger, 100),
+		ipp.IntAttr("job-priority-default", ipp.TagInteger, defaultJobPriority),
+		ipp.StringAttr("job-creation-attributes-supported", ipp.TagKeyword,
+			"copies", "sides", "media", "media-col", "orientation-requested",
+			"page-ranges", "print-quality", "print-color-mode", "job-hold-until", "job-priority"),
+	}
+}
+
+// parseJobTemplate 从任务属性组中解析任务模板，并返回打印机不支持的属性
+func parseJobTemplate(group *ipp.Group, support TemplateSupport) (JobTemplate, []ipp.Attribute) {
+	var t JobTemplate
+	var unsupported []ipp.Attribute
+
+	if attr, ok := group.Get("copies"); ok {
+		if n, ok := attr.Int(); ok && n >= 1 && n <= support.MaxCopies {
+			t.Copies = n
+		} else {
+			unsupported = append(unsupported, attr)
+		}
+	}
+
+	if attr, ok := group.Get("sides"); ok {
+		if s, ok := attr.Str(); ok && containsString(support.Sides, s) {
+			t.Sides = s
+		} else {
+			unsupported = append(unsupported, attr)
+		}
+	}
+
+	if attr, ok := group.Get("media"); ok {
+		if s, ok := attr.Str(); ok && containsString(support.Media, s) {
+			t.Media = s
+		} else {
+			unsupported = append(unsupported, attr)
+		}
+	} else if attr, ok := group.Get("media-col"); ok {
+		if media, ok := mediaFromCollection(attr, support.Media); ok {
+			t.Media = media
+		} else {
+			unsupported = append(unsupported, attr)
+		}
+	}
+
+	if attr, ok := group.Get("orientation-requested"); ok {
+		if n, ok := attr.Int(); ok && containsInt(support.Orientations, n) {
+			t.Orientation = n
+		} else {
+			unsupported = append(unsupported, attr)
+		}
+	}
+
+	if attr, ok := group.Get("page-ranges"); ok {
+		if ranges, ok := parsePageRanges(attr); ok {
+			t.PageRanges = ranges
+		} else {
+			unsupported = append(unsupported, attr)
+		}
+	}
+
+	if attr, ok := group.Get("print-quality"); ok {
+		if n, ok := attr.Int(); ok && containsInt(support.PrintQualities, n) {
+			t.PrintQuality = n
+		} else {
+			unsupported = append(unsupported, attr)
+		}
+	}
+
+	if attr, ok := group.Get("print-color-mode"); ok {
+		if s, ok := attr.Str(); ok && containsString(support.ColorModes, s) {
+			t.ColorMode = s
+		} else {
+			unsupported = append(unsupported, attr)
+		}
+	}
+
+	if attr, ok := group.Get("job-priority"); ok {
+		if n, ok := attr.Int(); ok && n >= 1 && n <= 100 {
+			t.Priority = n
+		} else {
+			unsupported = append(unsupported, attr)
+		}
+	}
+
+	return t, unsupported
+}
+
+// parsePageRanges 解析 page-ranges，要求各范围从 1 开始递增且互不重叠
+func parsePageRanges(attr ipp.Attribute) ([]ipp.Range, bool) {
+	var ranges []ipp.Range
+	var last int32
+	for _, tv := range attr.Values {
+		r, ok := tv.Value.(ipp.Range)
+		if !ok || r.Lower < 1 || r.Lower > r.Upper || r.Lower <= last {
+			return nil, false
+		}
+		ranges = append(ranges, r)
+		last = r.Upper
+	}
+	return ranges, len(ranges) > 0
+}
+
+// mediaSizePattern 匹配 PWG 媒体名称末尾的尺寸，如 "_210x297mm"、"_8.5x11in"
+var mediaSizePattern = regexp.MustCompile(`_([0-9.]+)x([0-9.]+)(mm|in)$`)
+
+// mediaSize 从 PWG 媒体名称中解析尺寸，单位为 1/100 毫米
+func mediaSize(name string) (width, height int, ok bool) {
+	m := mediaSizePattern.FindStringSubmatch(name)
+	if m == nil {
+		return 0, 0, false
+	}
+	w, err1 := strconv.ParseFloat(m[1], 64)
+	h, err2 := strconv.ParseFloat(m[2], 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	scale := 100.0
+	if m[3] == "in" {
+		scale = 2540.0
+	}
+	return int(w*scale + 0.5), int(h*scale + 0.5), true
+}
+
+// mediaFromCollection 根据 media-col 中的 media-size 在支持的媒体中查找匹配的名称
+//
+// 尺寸允许 ±1mm 误差，横向给出的尺寸同样匹配。
+func mediaFromCollection(attr ipp.Attribute, supported []string) (string, bool) {
+	if len(attr.Values) == 0 {
+		return "", false
+	}
+	col, ok := attr.Values[0].Value.(ipp.Collection)
+	if !ok {
+		return "", false
+	}
+	sizeAttr, ok := col.Get("media-size")
+	if !ok || len(sizeAttr.Values) == 0 {
+		return "", false
+	}
+	size, ok := sizeAttr.Values[0].Value.(ipp.Collection)
+	if !ok {
+		return "", false
+	}
+	xAttr, okX := size.Get("x-dimension")
+	yAttr, okY := size.Get("y-dimension")
+	if !okX || !okY {
+		return "", false
+	}
+	x, okX := xAttr.Int()
+	y, okY := yAttr.Int()
+	if !okX || !okY {
+		return "", false
+	}
+
+	for _, name := range supported {
+		w, h, ok := mediaSize(name)
+		if !ok {
+			continue
+		}
+		if (abs(w-x) <= 100 && abs(h-y) <= 100) || (abs(w-y) <= 100 && abs(h-x) <= 100) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// lpOptions 将任务模板转换为 CUPS lp 命令参数
+func (t JobTemplate) lpOptions() []string {
+	var args []string
+	if t.Copies > 1 {
+		args = append(args, "-n", strconv.Itoa(t.Copies))
+	}
+	if t.Sides != "" {
+		args = append(args, "-o", "sides="+t.Sides)
+	}
+	if t.Media != "" {
+		args = append(args, "-o", "media="+t.Media)
+	}
+	if t.Orientation != 0 {
+		args = append(args, "-o", fmt.Sprintf("orientation-requested=%d", t.Orientation))
+	}
+	if len(t.PageRanges) > 0 {
+		var parts []string
+		for _, r := range t.PageRanges {
+			if r.Lower == r.Upper {
+				parts = append(parts, strconv.Itoa(int(r.Lower)))
+			} else {
+				parts = append(parts, fmt.Sprintf("%d-%d", r.Lower, r.Upper))
+			}
+		}
+		args = append(args, "-P", strings.Join(parts, ","))
+	}
+	if t.PrintQuality != 0 {
+		args = append(args, "-o", fmt.Sprintf("print-quality=%d", t.PrintQuality))
+	}
+	if t.ColorMode != "" && t.ColorMode != "auto" {
+		args = append(args, "-o", "print-color-mode="+t.ColorMode)
+	}
+	if t.Priority != 0 {
+		args = append(args, "-q", strconv.Itoa(t.Priority))
+	}
+	return args
+}
+
+// containsString 判断 list 中是否包含 s
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// containsInt 判断 list 中是否包含 n
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// abs 返回整数的绝对值
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"airprint-service/ipp"
+)
+
+// testTemplateSupport 返回测试用的打印机任务模板能力
+func testTemplateSupport() TemplateSupport {
+	return TemplateSupport{
+		MaxCopies:      99,
+		Sides:          []string{"one-sided", "two-sided-long-edge"},
+		Media:          []string{"iso_a4_210x297mm", "na_letter_8.5x11in", "custom_label"},
+		DefaultMedia:   "iso_a4_210x297mm",
+		Orientations:   []int{3, 4},
+		PrintQualities: []int{4, 5},
+		ColorModes:     []string{"monochrome"},
+	}
+}
+
+// mediaColAttr 返回 media-col 属性，尺寸单位为 1/100 毫米
+func mediaColAttr(x, y int) ipp.Attribute {
+	return ipp.MakeAttr("media-col", ipp.TagBeginCollection, ipp.Collection{
+		ipp.MakeAttr("media-size", ipp.TagBeginCollection, ipp.Collection{
+			ipp.IntAttr("x-dimension", ipp.TagInteger, x),
+			ipp.IntAttr("y-dimension", ipp.TagInteger, y),
+		}),
+	})
+}
+
+// attrNames 返回属性名称列表
+func attrNames(attrs []ipp.Attribute) []string {
+	var names []string
+	for _, attr := range attrs {
+		names = append(names, attr.Name)
+	}
+	return names
+}
+
+func TestParseJobTemplate(t *testing.T) {
+	tests := []struct {
+		name        string
+		attrs       []ipp.Attribute
+		want        JobTemplate
+		unsupported []string
+	}{
+		{"no attributes", nil, JobTemplate{}, nil},
+		{"copies", []ipp.Attribute{ipp.IntAttr("copies", ipp.TagInteger, 3)}, JobTemplate{Copies: 3}, nil},
+		{"copies at maximum", []ipp.Attribute{ipp.IntAttr("copies", ipp.TagInteger, 99)}, JobTemplate{Copies: 99}, nil},
+		{"zero copies", []ipp.Attribute{ipp.IntAttr("copies", ipp.TagInteger, 0)}, JobTemplate{}, []string{"copies"}},
+		{"too many copies", []ipp.Attribute{ipp.IntAttr("copies", ipp.TagInteger, 100)}, JobTemplate{}, []string{"copies"}},
+		{"copies as keyword", []ipp.Attribute{ipp.StringAttr("copies", ipp.TagKeyword, "3")}, JobTemplate{}, []string{"copies"}},
+		{"sides", []ipp.Attribute{ipp.StringAttr("sides", ipp.TagKeyword, "two-sided-long-edge")},
+			JobTemplate{Sides: "two-sided-long-edge"}, nil},
+		{"unsupported sides", []ipp.Attribute{ipp.StringAttr("sides", ipp.TagKeyword, "two-sided-short-edge")},
+			JobTemplate{}, []string{"sides"}},
+		{"media", []ipp.Attribute{ipp.StringAttr("media", ipp.TagKeyword, "na_letter_8.5x11in")},
+			JobTemplate{Media: "na_letter_8.5x11in"}, nil},
+		{"unsupported media", []ipp.Attribute{ipp.StringAttr("media", ipp.TagKeyword, "iso_a3_297x420mm")},
+			JobTemplate{}, []string{"media"}},
+		{"media-col size", []ipp.Attribute{mediaColAttr(21000, 29700)}, JobTemplate{Media: "iso_a4_210x297mm"}, nil},
+		{"media-col within 1mm", []ipp.Attribute{mediaColAttr(21590, 27840)}, JobTemplate{Media: "na_letter_8.5x11in"}, nil},
+		{"media-col landscape", []ipp.Attribute{mediaColAttr(29700, 21000)}, JobTemplate{Media: "iso_a4_210x297mm"}, nil},
+		{"media-col unknown size", []ipp.Attribute{mediaColAttr(10000, 15000)}, JobTemplate{}, []string{"media-col"}},
+		{"media-col without size", []ipp.Attribute{ipp.MakeAttr("media-col", ipp.TagBeginCollection, ipp.Collection{
+			ipp.StringAttr("media-type", ipp.TagKeyword, "stationery"),
+		})}, JobTemplate{}, []string{"media-col"}},
+		{"media wins over media-col", []ipp.Attribute{
+			ipp.StringAttr("media", ipp.TagKeyword, "na_letter_8.5x11in"),
+			mediaColAttr(21000, 29700),
+		}, JobTemplate{Media: "na_letter_8.5x11in"}, nil},
+		{"orientation", []ipp.Attribute{ipp.IntAttr("orientation-requested", ipp.TagEnum, 4)}, JobTemplate{Orientation: 4}, nil},
+		{"unsupported orientation", []ipp.Attribute{ipp.IntAttr("orientation-requested", ipp.TagEnum, 5)},
+			JobTemplate{}, []string{"orientation-requested"}},
+		{"page ranges", []ipp.Attribute{ipp.MakeAttr("page-ranges", ipp.TagRange,
+			ipp.Range{Lower: 1, Upper: 2}, ipp.Range{Lower: 5, Upper: 5})},
+			JobTemplate{PageRanges: []ipp.Range{{Lower: 1, Upper: 2}, {Lower: 5, Upper: 5}}}, nil},
+		{"overlapping page ranges", []ipp.Attribute{ipp.MakeAttr("page-ranges", ipp.TagRange,
+			ipp.Range{Lower: 1, Upper: 3}, ipp.Range{Lower: 3, Upper: 4})}, JobTemplate{}, []string{"page-ranges"}},
+		{"page range from zero", []ipp.Attribute{ipp.MakeAttr("page-ranges", ipp.TagRange, ipp.Range{Lower: 0, Upper: 1})},
+			JobTemplate{}, []string{"page-ranges"}},
+		{"print quality", []ipp.Attribute{ipp.IntAttr("print-quality", ipp.TagEnum, 5)}, JobTemplate{PrintQuality: 5}, nil},
+		{"unsupported print quality", []ipp.Attribute{ipp.IntAttr("print-quality", ipp.TagEnum, 3)},
+			JobTemplate{}, []string{"print-quality"}},
+		{"color mode", []ipp.Attribute{ipp.StringAttr("print-color-mode", ipp.TagKeyword, "monochrome")},
+			JobTemplate{ColorMode: "monochrome"}, nil},
+		{"unsupported color mode", []ipp.Attribute{ipp.StringAttr("print-color-mode", ipp.TagKeyword, "color")},
+			JobTemplate{}, []string{"print-color-mode"}},
+		{"priority", []ipp.Attribute{ipp.IntAttr("job-priority", ipp.TagInteger, 100)}, JobTemplate{Priority: 100}, nil},
+		{"zero priority", []ipp.Attribute{ipp.IntAttr("job-priority", ipp.TagInteger, 0)}, JobTemplate{}, []string{"job-priority"}},
+		{"priority too high", []ipp.Attribute{ipp.IntAttr("job-priority", ipp.TagInteger, 101)}, JobTemplate{}, []string{"job-priority"}},
+		{"supported values kept with unsupported ones", []ipp.Attribute{
+			ipp.IntAttr("copies", ipp.TagInteger, 2),
+			ipp.StringAttr("sides", ipp.TagKeyword, "two-sided-short-edge"),
+			ipp.IntAttr("print-quality", ipp.TagEnum, 4),
+			ipp.IntAttr("job-priority", ipp.TagInteger, 200),
+		}, JobTemplate{Copies: 2, PrintQuality: 4}, []string{"sides", "job-priority"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := &ipp.Group{Tag: ipp.TagJobGroup, Attrs: tt.attrs}
+			got, unsupported := parseJobTemplate(group, testTemplateSupport())
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("template = %+v, want %+v", got, tt.want)
+			}
+			if names := attrNames(unsupported); !reflect.DeepEqual(names, tt.unsupported) {
+				t.Errorf("unsupported = %q, want %q", names, tt.unsupported)
+			}
+		})
+	}
+}
+
+func TestParseJobTemplateWithoutJobGroup(t *testing.T) {
+	got, unsupported := parseJobTemplate(nil, testTemplateSupport())
+	if !reflect.DeepEqual(got, JobTemplate{}) || len(unsupported) != 0 {
+		t.Errorf("parseJobTemplate(nil) = %+v, %v", got, unsupported)
+	}
+}
+
+func TestUnsupportedAttributesResponse(t *testing.T) {
+	unsupported := []ipp.Attribute{
+		ipp.StringAttr("sides", ipp.TagKeyword, "two-sided-short-edge"),
+		ipp.IntAttr("copies", ipp.TagInteger, 0),
+	}
+	tests := []struct {
+		name     string
+		fidelity *bool
+		rejected bool
+	}{
+		{"fidelity true", boolPtr(true), true},
+		{"fidelity false", boolPtr(false), false},
+		{"fidelity not given", nil, false},
+	}
+	a := &AirPrintServer{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := ipp.NewRequest(ipp.Version20, ipp.OpPrintJob, 9)
+			if tt.fidelity != nil {
+				req.Operation().Add(ipp.BoolAttr("ipp-attribute-fidelity", *tt.fidelity))
+			}
+
+			rejected := a.rejectUnsupported(req, unsupported)
+			if (rejected != nil) != tt.rejected {
+				t.Fatalf("rejected = %v, want %v", rejected != nil, tt.rejected)
+			}
+			response := rejected
+			status := ipp.StatusErrorAttributesOrValues
+			if response == nil {
+				response = a.withUnsupported(a.newResponse(req, ipp.StatusOK), unsupported)
+				status = ipp.StatusOKIgnoredOrSubstituted
+			}
+			if response.Status() != status {
+				t.Errorf("status = %v, want %v", response.Status(), status)
+			}
+			if response.RequestID != 9 {
+				t.Errorf("request id = %d, want 9", response.RequestID)
+			}
+			group := response.Group(ipp.TagUnsupportedGroup)
+			if group == nil || !reflect.DeepEqual(group.Attrs, unsupported) {
+				t.Errorf("unsupported-attributes group = %+v, want %+v", group, unsupported)
+			}
+		})
+	}
+
+	// 没有不支持的属性时响应不变
+	req := ipp.NewRequest(ipp.Version20, ipp.OpPrintJob, 9)
+	req.Operation().Add(ipp.BoolAttr("ipp-attribute-fidelity", true))
+	if rejected := a.rejectUnsupported(req, nil); rejected != nil {
+		t.Error("request rejected without unsupported attributes")
+	}
+	response := a.withUnsupported(a.newResponse(req, ipp.StatusOK), nil)
+	if response.Status() != ipp.StatusOK || response.Group(ipp.TagUnsupportedGroup) != nil {
+		t.Error("response changed without unsupported attributes")
+	}
+}
+
+// boolPtr 返回指向 b 的指针
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,56 @@
+package label
+
+import "image/color"
+
+// Bitmap 单色位图，每行按字节对齐，每字节高位在左，1 表示黑点（打印）
+type Bitmap struct {
+	Width  int
+	Height int
+	Stride int // 每行字节数
+	Pix    []byte
+}
+
+// NewBitmap 创建全白的位图
+func NewBitmap(width, height int) *Bitmap {
+	stride := (width + 7) / 8
+	return &Bitmap{
+		Width:  width,
+		Height: height,
+		Stride: stride,
+		Pix:    make([]byte, stride*height),
+	}
+}
+
+// Set 设置一个点，超出范围时忽略
+func (b *Bitmap) Set(x, y int, black bool) {
+	if x < 0 || y < 0 || x >= b.Width || y >= b.Height {
+		return
+	}
+	mask := byte(0x80 >> uint(x%8))
+	if black {
+		b.Pix[y*b.Stride+x/8] |= mask
+	} else {
+		b.Pix[y*b.Stride+x/8] &^= mask
+	}
+}
+
+// Black 判断一个点是否为黑点，超出范围时为白点
+func (b *Bitmap) Black(x, y int) bool {
+	if x < 0 || y < 0 || x >= b.Width || y >= b.Height {
+		return false
+	}
+	return b.Pix[y*b.Stride+x/8]&(0x80>>uint(x%8)) != 0
+}
+
+// Row 返回第 y 行的数据
+func (b *Bitmap) Row(y int) []byte {
+	return b.Pix[y*b.Stride : (y+1)*b.Stride]
+}
+
+// luminance 返回颜色叠加在白色背景上的亮度（0-0xffff）
+func luminance(c color.Color) uint32 {
+	r, g, b, a := c.RGBA()
+	// RGBA 返回预乘 alpha 的值，加上背景白色透过的部分
+	y := (299*r + 587*g + 114*b) / 1000
+	return y + (0xffff - a)
+}
@@ -0,0 +1,42 @@
+// Package label 生成热敏标签打印机的指令：将单色标签位图编码为各种打印机语言的图形命令。
+package label
+
+import (
+	"encoding/hex"
+	"io"
+)
+
+// Settings 标签的打印设置，尺寸与偏移的单位为点
+type Settings struct {
+	Width      int // 标签宽度
+	Height     int // 标签长度（走纸方向）
+	OffsetX    int // 水平偏移，正值向右
+	OffsetY    int // 垂直偏移，正值向下
+	Speed      int // 打印速度，0 表示使用打印机设置
+	Darkness   int // 打印浓度，0 表示使用打印机设置
+	Quantity   int // 打印份数
+	Resolution int // 打印机分辨率（dpi），用于以毫米设置标签尺寸的指令
+}
+
+// quantity 返回打印份数，未设置时为 1
+func (s Settings) quantity() int {
+	if s.Quantity < 1 {
+		return 1
+	}
+	return s.Quantity
+}
+
+// Encoder 将一页标签位图编码为打印机指令
+type Encoder func(w io.Writer, page *Bitmap, s Settings) error
+
+// hexUpper 返回大写十六进制字符串，用于以 ASCII 发送图形数据的指令
+func hexUpper(data []byte) string {
+	buf := make([]byte, hex.EncodedLen(len(data)))
+	hex.Encode(buf, data)
+	for i, c := range buf {
+		if c >= 'a' && c <= 'f' {
+			buf[i] = c - 'a' + 'A'
+		}
+	}
+	return string(buf)
+}
@@ -0,0 +1,201 @@
+package label
+
+import "image"
+
+// Dither 灰度转换为单色时使用的半色调方法
+type Dither string
+
+const (
+	// DitherThreshold 按阈值直接二值化，适合条码与文字
+	DitherThreshold Dither = "threshold"
+	// DitherFloydSteinberg Floyd-Steinberg 误差扩散，适合照片
+	DitherFloydSteinberg Dither = "floyd-steinberg"
+	// DitherOrdered 8×8 Bayer 有序抖动，图案规则，适合大面积灰色
+	DitherOrdered Dither = "ordered"
+)
+
+// Valid 判断是否为支持的半色调方法，空字符串表示默认的阈值方法
+func (d Dither) Valid() bool {
+	switch d {
+	case "", DitherThreshold, DitherFloydSteinberg, DitherOrdered:
+		return true
+	}
+	return false
+}
+
+// RenderOptions 图像渲染为标签位图的设置，尺寸单位为点
+type RenderOptions struct {
+	Width     int    // 标签宽度
+	Height    int    // 标签长度（走纸方向）
+	Margin    int    // 四周留白
+	Rotation  int    // 图像顺时针旋转的角度：0、90、180、270
+	Dither    Dither // 半色调方法，空字符串表示阈值方法
+	Threshold int    // 阈值方法的黑白分界亮度（1-255），0 表示 128
+}
+
+// threshold 返回阈值方法使用的分界亮度
+func (o RenderOptions) threshold() int {
+	if o.Threshold < 1 || o.Threshold > 255 {
+		return 128
+	}
+	return o.Threshold
+}
+
+// bayer8 8×8 Bayer 矩阵，取值 0-63
+var bayer8 = [8][8]int{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// Render 将图像旋转后等比缩放到留白以内的区域并居中，再按半色调方法转换为单色位图
+//
+// 缩小时对每个点覆盖的源像素取平均，避免细线与灰度丢失；透明部分视为白色。
+func Render(img image.Image, opt RenderOptions) *Bitmap {
+	bitmap := NewBitmap(opt.Width, opt.Height)
+	gray := rotateGray(img, opt.Rotation)
+	areaW, areaH := opt.Width-2*opt.Margin, opt.Height-2*opt.Margin
+	if gray.Rect.Empty() || areaW <= 0 || areaH <= 0 {
+		return bitmap
+	}
+
+	// 按较小的缩放比例保持宽高比
+	iw, ih := gray.Rect.Dx(), gray.Rect.Dy()
+	sw, sh := areaW, ih*areaW/iw
+	if iw*areaH <= ih*areaW {
+		sw, sh = iw*areaH/ih, areaH
+	}
+	if sw == 0 || sh == 0 {
+		return bitmap
+	}
+	scaled := resample(gray, sw, sh)
+
+	ox, oy := opt.Margin+(areaW-sw)/2, opt.Margin+(areaH-sh)/2
+	switch opt.Dither {
+	case DitherFloydSteinberg:
+		floydSteinberg(bitmap, scaled, ox, oy)
+	case DitherOrdered:
+		for y := 0; y < sh; y++ {
+			for x := 0; x < sw; x++ {
+				level := (bayer8[y%8][x%8]*2 + 1) * 255 / 128
+				if int(scaled.Pix[y*scaled.Stride+x]) < level {
+					bitmap.Set(ox+x, oy+y, true)
+				}
+			}
+		}
+	default:
+		level := opt.threshold()
+		for y := 0; y < sh; y++ {
+			for x := 0; x < sw; x++ {
+				if int(scaled.Pix[y*scaled.Stride+x]) < level {
+					bitmap.Set(ox+x, oy+y, true)
+				}
+			}
+		}
+	}
+	return bitmap
+}
+
+// rotateGray 将图像转换为叠加在白色背景上的灰度图，并顺时针旋转 rotation 度
+func rotateGray(img image.Image, rotation int) *image.Gray {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	rotation = ((rotation % 360) + 360) % 360 / 90 * 90
+
+	dw, dh := w, h
+	if rotation == 90 || rotation == 270 {
+		dw, dh = h, w
+	}
+	gray := image.NewGray(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := x, y
+			switch rotation {
+			case 90:
+				dx, dy = h-1-y, x
+			case 180:
+				dx, dy = w-1-x, h-1-y
+			case 270:
+				dx, dy = y, w-1-x
+			}
+			gray.Pix[dy*gray.Stride+dx] = uint8(luminance(img.At(bounds.Min.X+x, bounds.Min.Y+y)) >> 8)
+		}
+	}
+	return gray
+}
+
+// resample 将灰度图缩放为 width×height：缩小时取源区域的平均值，放大时取最近的像素
+func resample(src *image.Gray, width, height int) *image.Gray {
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, (y+1)*sh/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, (x+1)*sw/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			sum := 0
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					sum += int(row[sx])
+				}
+			}
+			dst.Pix[y*dst.Stride+x] = uint8(sum / ((y1 - y0) * (x1 - x0)))
+		}
+	}
+	return dst
+}
+
+// floydSteinberg 以 Floyd-Steinberg 误差扩散将灰度图转换为黑点，写入位图的 (ox, oy) 处
+//
+// 按蛇形顺序逐行扫描，减少误差单向扩散产生的纹理。
+func floydSteinberg(bitmap *Bitmap, gray *image.Gray, ox, oy int) {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	cur := make([]int, w+2)
+	next := make([]int, w+2)
+	for x := 0; x < w; x++ {
+		cur[x+1] = int(gray.Pix[x]) * 16
+	}
+
+	for y := 0; y < h; y++ {
+		for i := range next {
+			next[i] = 0
+		}
+		if y+1 < h {
+			row := gray.Pix[(y+1)*gray.Stride:]
+			for x := 0; x < w; x++ {
+				next[x+1] = int(row[x]) * 16
+			}
+		}
+
+		dir, start, end := 1, 0, w
+		if y%2 == 1 {
+			dir, start, end = -1, w-1, -1
+		}
+		for x := start; x != end; x += dir {
+			old := cur[x+1]
+			value := 0
+			if old >= 128*16 {
+				value = 255 * 16
+			} else {
+				bitmap.Set(ox+x, oy+y, true)
+			}
+			e := (old - value) / 16
+			cur[x+1+dir] += e * 7
+			next[x+1-dir] += e * 3
+			next[x+1] += e * 5
+			next[x+1+dir] += e * 1
+		}
+		cur, next = next, cur
+	}
+}
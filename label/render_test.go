@@ -0,0 +1,182 @@
+package label
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// uniformGray 返回 width×height、亮度全部为 v 的灰度图
+func uniformGray(width, height int, v uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = v
+	}
+	return img
+}
+
+// blackBounds 返回位图中黑点所在的最小矩形
+func blackBounds(b *Bitmap) image.Rectangle {
+	var r image.Rectangle
+	for y := 0; y < b.Height; y++ {
+		for x := 0; x < b.Width; x++ {
+			if b.Black(x, y) {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+// blackCount 返回位图中的黑点数
+func blackCount(b *Bitmap) int {
+	n := 0
+	for y := 0; y < b.Height; y++ {
+		for x := 0; x < b.Width; x++ {
+			if b.Black(x, y) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestRenderRotationAndScaling(t *testing.T) {
+	// 40×20 的全黑图像缩放到 100×60 标签、四周留白 5 点的区域（90×50）中居中
+	img := uniformGray(40, 20, 0)
+	tests := []struct {
+		rotation int
+		want     image.Rectangle
+	}{
+		{0, image.Rect(5, 7, 95, 52)},    // 90×45
+		{90, image.Rect(37, 5, 62, 55)},  // 25×50
+		{180, image.Rect(5, 7, 95, 52)},  // 90×45
+		{270, image.Rect(37, 5, 62, 55)}, // 25×50
+		{-90, image.Rect(37, 5, 62, 55)}, // 同 270
+		{450, image.Rect(37, 5, 62, 55)}, // 同 90
+	}
+	for _, tt := range tests {
+		bitmap := Render(img, RenderOptions{Width: 100, Height: 60, Margin: 5, Rotation: tt.rotation})
+		if bitmap.Width != 100 || bitmap.Height != 60 {
+			t.Fatalf("rotation %d: bitmap is %dx%d, want 100x60", tt.rotation, bitmap.Width, bitmap.Height)
+		}
+		if got := blackBounds(bitmap); got != tt.want {
+			t.Errorf("rotation %d: image placed at %v, want %v", tt.rotation, got, tt.want)
+		}
+		if got, want := blackCount(bitmap), tt.want.Dx()*tt.want.Dy(); got != want {
+			t.Errorf("rotation %d: %d black dots, want %d", tt.rotation, got, want)
+		}
+	}
+}
+
+func TestRotateGrayDirection(t *testing.T) {
+	// 2×1 图像左黑右白，顺时针旋转后黑点分别位于上、右、下、左
+	img := image.NewGray(image.Rect(0, 0, 2, 1))
+	img.Pix[1] = 0xff
+	tests := []struct {
+		rotation int
+		black    image.Point
+		size     image.Point
+	}{
+		{0, image.Pt(0, 0), image.Pt(2, 1)},
+		{90, image.Pt(0, 0), image.Pt(1, 2)},
+		{180, image.Pt(1, 0), image.Pt(2, 1)},
+		{270, image.Pt(0, 1), image.Pt(1, 2)},
+	}
+	for _, tt := range tests {
+		gray := rotateGray(img, tt.rotation)
+		if size := gray.Rect.Size(); size != tt.size {
+			t.Fatalf("rotation %d: size %v, want %v", tt.rotation, size, tt.size)
+		}
+		if v := gray.GrayAt(tt.black.X, tt.black.Y).Y; v != 0 {
+			t.Errorf("rotation %d: pixel %v = %d, want black", tt.rotation, tt.black, v)
+		}
+	}
+}
+
+func TestRenderTransparentIsWhite(t *testing.T) {
+	// 左半边为完全透明的黑色，右半边为不透明的黑色
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		img.Set(2, y, color.NRGBA{A: 0xff})
+		img.Set(3, y, color.NRGBA{A: 0xff})
+	}
+	bitmap := Render(img, RenderOptions{Width: 4, Height: 4})
+	if got, want := blackBounds(bitmap), image.Rect(2, 0, 4, 4); got != want || blackCount(bitmap) != 8 {
+		t.Errorf("black dots at %v (%d), want only the opaque half %v", got, blackCount(bitmap), want)
+	}
+}
+
+func TestRenderMarginTooLarge(t *testing.T) {
+	bitmap := Render(uniformGray(4, 4, 0), RenderOptions{Width: 10, Height: 10, Margin: 5})
+	if bitmap.Width != 10 || bitmap.Height != 10 || blackCount(bitmap) != 0 {
+		t.Error("image rendered although the margins leave no printable area")
+	}
+}
+
+func TestThresholdDither(t *testing.T) {
+	// 8 点的渐变 0, 32, ..., 224，尺寸与标签相同，不缩放
+	gradient := image.NewGray(image.Rect(0, 0, 8, 1))
+	for x := 0; x < 8; x++ {
+		gradient.Pix[x] = uint8(x * 32)
+	}
+	tests := []struct {
+		threshold int
+		want      byte
+	}{
+		{0, 0xf0},   // 默认 128：0-96 为黑点
+		{64, 0xc0},  // 0、32
+		{255, 0xff}, // 全部低于 255
+		{1, 0x80},   // 只有 0
+	}
+	for _, tt := range tests {
+		bitmap := Render(gradient, RenderOptions{Width: 8, Height: 1, Threshold: tt.threshold})
+		if got := bitmap.Pix[0]; got != tt.want {
+			t.Errorf("threshold %d: row = %08b, want %08b", tt.threshold, got, tt.want)
+		}
+	}
+}
+
+func TestFloydSteinbergDither(t *testing.T) {
+	// 50% 灰：第一个点 128 不低于分界为白点，误差 -127 使下一个点为黑点，此后黑白交替
+	bitmap := Render(uniformGray(8, 1, 128), RenderOptions{Width: 8, Height: 1, Dither: DitherFloydSteinberg})
+	if got := bitmap.Pix[0]; got != 0x55 {
+		t.Errorf("row = %08b, want %08b", got, 0x55)
+	}
+
+	// 较大面积的灰度，黑点比例接近 1 - 亮度/255
+	for _, v := range []uint8{32, 128, 192} {
+		bitmap := Render(uniformGray(64, 64, v), RenderOptions{Width: 64, Height: 64, Dither: DitherFloydSteinberg})
+		got := float64(blackCount(bitmap)) / (64 * 64)
+		want := 1 - float64(v)/255
+		if got < want-0.03 || got > want+0.03 {
+			t.Errorf("gray %d: black ratio %.3f, want about %.3f", v, got, want)
+		}
+	}
+}
+
+func TestOrderedDither(t *testing.T) {
+	// 亮度 128 时 Bayer 矩阵中值不小于 32 的位置为黑点，每 8×8 块恰好 32 个
+	bitmap := Render(uniformGray(8, 8, 128), RenderOptions{Width: 8, Height: 8, Dither: DitherOrdered})
+	if n := blackCount(bitmap); n != 32 {
+		t.Errorf("%d black dots, want 32", n)
+	}
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if want := bayer8[y][x] >= 32; bitmap.Black(x, y) != want {
+				t.Fatalf("dot (%d, %d) black = %v, want %v", x, y, !want, want)
+			}
+		}
+	}
+}
+
+func TestDitherValid(t *testing.T) {
+	for _, d := range []Dither{"", DitherThreshold, DitherFloydSteinberg, DitherOrdered} {
+		if !d.Valid() {
+			t.Errorf("%q reported invalid", d)
+		}
+	}
+	if Dither("atkinson").Valid() {
+		t.Error("unknown dither method reported valid")
+	}
+}
@@ -0,0 +1,74 @@
+package label
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// esc SBPL 命令前缀
+const esc = 0x1b
+
+// sbplMaxBlocks ESC G 命令中横向字节数与纵向 8 点块数的最大值（3 位数字）
+const sbplMaxBlocks = 999
+
+// EncodeSBPL 将一页位图编码为 SATO SBPL 指令（ESC A ... ESC Z）
+//
+// 位图通过 ESC GH（十六进制图形）命令打印在标签左上角，Speed 与 Darkness 为 0 时
+// 不发送对应命令。
+func EncodeSBPL(w io.Writer, page *Bitmap, s Settings) error {
+	blocksX := page.Stride
+	blocksY := (page.Height + 7) / 8
+	if blocksX > sbplMaxBlocks || blocksY > sbplMaxBlocks {
+		return fmt.Errorf("label: bitmap %dx%d too large for SBPL graphic", page.Width, page.Height)
+	}
+
+	bw := bufio.NewWriter(w)
+	cmd := func(format string, args ...interface{}) {
+		bw.WriteByte(esc)
+		fmt.Fprintf(bw, format, args...)
+	}
+
+	cmd("A")
+	cmd("A1%04d%04d", s.Height, s.Width)
+	cmd("A3V%s%04dH%s%04d", sign(s.OffsetY), abs(s.OffsetY), sign(s.OffsetX), abs(s.OffsetX))
+	if s.Speed > 0 {
+		cmd("CS%d", s.Speed)
+	}
+	if s.Darkness > 0 {
+		cmd("#E%d", s.Darkness)
+	}
+
+	// 图形高度按 8 点对齐，不足的行补白
+	cmd("V%04d", 0)
+	cmd("H%04d", 0)
+	cmd("GH%03d%03d", blocksX, blocksY)
+	blank := make([]byte, page.Stride)
+	for y := 0; y < blocksY*8; y++ {
+		row := blank
+		if y < page.Height {
+			row = page.Row(y)
+		}
+		bw.WriteString(hexUpper(row))
+	}
+
+	cmd("Q%d", s.quantity())
+	cmd("Z")
+	return bw.Flush()
+}
+
+// sign 返回偏移量的符号
+func sign(n int) string {
+	if n < 0 {
+		return "-"
+	}
+	return "+"
+}
+
+// abs 返回绝对值
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,77 @@
+package label
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// testBitmap 返回 10×3 的测试位图，每行 2 字节：
+// 第一行两端为黑点（80 40），第二行全黑（FF C0，行尾不足一字节的部分为 0），第三行全白
+func testBitmap() *Bitmap {
+	b := NewBitmap(10, 3)
+	b.Set(0, 0, true)
+	b.Set(9, 0, true)
+	for x := 0; x < 10; x++ {
+		b.Set(x, 1, true)
+	}
+	return b
+}
+
+func TestEncodeSBPL(t *testing.T) {
+	var buf bytes.Buffer
+	s := Settings{Width: 400, Height: 240, OffsetX: 8, OffsetY: -4, Speed: 4, Darkness: 2, Quantity: 3}
+	if err := EncodeSBPL(&buf, testBitmap(), s); err != nil {
+		t.Fatal(err)
+	}
+
+	// 图形高度补足 8 点：3 行数据之后是 5 行空白
+	want := "\x1bA" +
+		"\x1bA102400400" +
+		"\x1bA3V-0004H+0008" +
+		"\x1bCS4" +
+		"\x1b#E2" +
+		"\x1bV0000" +
+		"\x1bH0000" +
+		"\x1bGH002001" + "8040" + "FFC0" + "0000" + strings.Repeat("0000", 5) +
+		"\x1bQ3" +
+		"\x1bZ"
+	if got := buf.String(); got != want {
+		t.Errorf("EncodeSBPL =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestEncodeSBPLDefaults(t *testing.T) {
+	// 速度与浓度为 0 时不发送，份数未设置时为 1
+	var buf bytes.Buffer
+	page := NewBitmap(16, 9)
+	page.Set(15, 8, true)
+	if err := EncodeSBPL(&buf, page, Settings{Width: 16, Height: 9}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "\x1bA" +
+		"\x1bA100090016" +
+		"\x1bA3V+0000H+0000" +
+		"\x1bV0000" +
+		"\x1bH0000" +
+		"\x1bGH002002" + strings.Repeat("0000", 8) + "0001" + strings.Repeat("0000", 7) +
+		"\x1bQ1" +
+		"\x1bZ"
+	if got := buf.String(); got != want {
+		t.Errorf("EncodeSBPL =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestEncodeSBPLTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeSBPL(&buf, NewBitmap(8*1000, 8), Settings{}); err == nil {
+		t.Error("EncodeSBPL accepted a bitmap wider than 999 bytes")
+	}
+	if err := EncodeSBPL(&buf, NewBitmap(8, 8*1000), Settings{}); err == nil {
+		t.Error("EncodeSBPL accepted a bitmap taller than 999 blocks")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("EncodeSBPL wrote %d bytes before failing", buf.Len())
+	}
+}
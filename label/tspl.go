@@ -0,0 +1,54 @@
+package label
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// EncodeTSPL 将一页位图编码为 TSC TSPL/TSPL2 指令
+//
+// 标签尺寸以毫米设置（需要 Resolution），位图通过 BITMAP 命令以二进制数据发送。
+// TSPL 位图中 0 表示黑点，与 Bitmap 相反。Speed 与 Darkness 为 0 时不发送 SPEED 与 DENSITY。
+func EncodeTSPL(w io.Writer, page *Bitmap, s Settings) error {
+	if s.Resolution <= 0 {
+		return fmt.Errorf("label: TSPL requires printer resolution")
+	}
+
+	bw := bufio.NewWriter(w)
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(bw, format, args...)
+		bw.WriteString("\r\n")
+	}
+
+	line("SIZE %s mm,%s mm", dotsToMM(s.Width, s.Resolution), dotsToMM(s.Height, s.Resolution))
+	if s.Speed > 0 {
+		line("SPEED %d", s.Speed)
+	}
+	if s.Darkness > 0 {
+		line("DENSITY %d", s.Darkness)
+	}
+	if s.OffsetX != 0 || s.OffsetY != 0 {
+		line("SHIFT %d,%d", s.OffsetX, s.OffsetY)
+	}
+	line("CLS")
+
+	fmt.Fprintf(bw, "BITMAP 0,0,%d,%d,0,", page.Stride, page.Height)
+	row := make([]byte, page.Stride)
+	for y := 0; y < page.Height; y++ {
+		for i, b := range page.Row(y) {
+			row[i] = ^b
+		}
+		bw.Write(row)
+	}
+	bw.WriteString("\r\n")
+
+	line("PRINT 1,%d", s.quantity())
+	return bw.Flush()
+}
+
+// dotsToMM 将点换算为毫米，保留一位小数
+func dotsToMM(dots, dpi int) string {
+	return strconv.FormatFloat(float64(dots)*25.4/float64(dpi), 'f', 1, 64)
+}
@@ -0,0 +1,51 @@
+package label
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeTSPL(t *testing.T) {
+	var buf bytes.Buffer
+	s := Settings{Width: 406, Height: 203, OffsetX: 8, OffsetY: -4, Speed: 4, Darkness: 8, Quantity: 2, Resolution: 203}
+	if err := EncodeTSPL(&buf, testBitmap(), s); err != nil {
+		t.Fatal(err)
+	}
+
+	// TSPL 位图中 0 为黑点：80 40 / FF C0 / 00 00 取反后为 7F BF / 00 3F / FF FF
+	want := "SIZE 50.8 mm,25.4 mm\r\n" +
+		"SPEED 4\r\n" +
+		"DENSITY 8\r\n" +
+		"SHIFT 8,-4\r\n" +
+		"CLS\r\n" +
+		"BITMAP 0,0,2,3,0," + "\x7f\xbf" + "\x00\x3f" + "\xff\xff" + "\r\n" +
+		"PRINT 1,2\r\n"
+	if got := buf.String(); got != want {
+		t.Errorf("EncodeTSPL =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestEncodeTSPLDefaults(t *testing.T) {
+	// 没有偏移、速度与浓度时不发送对应命令，份数未设置时为 1
+	var buf bytes.Buffer
+	page := NewBitmap(8, 1)
+	page.Set(0, 0, true)
+	if err := EncodeTSPL(&buf, page, Settings{Width: 300, Height: 150, Resolution: 300}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "SIZE 25.4 mm,12.7 mm\r\n" +
+		"CLS\r\n" +
+		"BITMAP 0,0,1,1,0,\x7f\r\n" +
+		"PRINT 1,1\r\n"
+	if got := buf.String(); got != want {
+		t.Errorf("EncodeTSPL =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestEncodeTSPLRequiresResolution(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeTSPL(&buf, testBitmap(), Settings{Width: 400, Height: 240}); err == nil {
+		t.Error("EncodeTSPL succeeded without a printer resolution")
+	}
+}
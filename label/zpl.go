@@ -0,0 +1,42 @@
+package label
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// EncodeZPL 将一页位图编码为 Zebra ZPL II 指令（^XA ... ^XZ）
+//
+// 位图通过 ^GFA（ASCII 十六进制图形）字段打印在标签原点；偏移通过 ^LS 与 ^LT 实现，
+// Speed 与 Darkness 为 0 时不发送 ^PR 与 ~SD。
+func EncodeZPL(w io.Writer, page *Bitmap, s Settings) error {
+	bw := bufio.NewWriter(w)
+
+	if s.Darkness > 0 {
+		fmt.Fprintf(bw, "~SD%02d\n", s.Darkness)
+	}
+	bw.WriteString("^XA\n")
+	fmt.Fprintf(bw, "^PW%d\n^LL%d\n", s.Width, s.Height)
+	if s.OffsetX != 0 {
+		// ^LS 为正时内容左移
+		fmt.Fprintf(bw, "^LS%d\n", -s.OffsetX)
+	}
+	if s.OffsetY != 0 {
+		fmt.Fprintf(bw, "^LT%d\n", s.OffsetY)
+	}
+	if s.Speed > 0 {
+		fmt.Fprintf(bw, "^PR%d\n", s.Speed)
+	}
+
+	total := page.Stride * page.Height
+	fmt.Fprintf(bw, "^FO0,0^GFA,%d,%d,%d,", total, total, page.Stride)
+	for y := 0; y < page.Height; y++ {
+		bw.WriteString(hexUpper(page.Row(y)))
+	}
+	bw.WriteString("^FS\n")
+
+	fmt.Fprintf(bw, "^PQ%d\n", s.quantity())
+	bw.WriteString("^XZ\n")
+	return bw.Flush()
+}
@@ -0,0 +1,49 @@
+package label
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeZPL(t *testing.T) {
+	var buf bytes.Buffer
+	s := Settings{Width: 400, Height: 240, OffsetX: 8, OffsetY: -4, Speed: 4, Darkness: 15, Quantity: 2}
+	if err := EncodeZPL(&buf, testBitmap(), s); err != nil {
+		t.Fatal(err)
+	}
+
+	// ^GFA 的总字节数与字段字节数均为 2 字节/行 × 3 行
+	want := "~SD15\n" +
+		"^XA\n" +
+		"^PW400\n" +
+		"^LL240\n" +
+		"^LS-8\n" +
+		"^LT-4\n" +
+		"^PR4\n" +
+		"^FO0,0^GFA,6,6,2,8040FFC00000^FS\n" +
+		"^PQ2\n" +
+		"^XZ\n"
+	if got := buf.String(); got != want {
+		t.Errorf("EncodeZPL =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestEncodeZPLDefaults(t *testing.T) {
+	// 没有偏移、速度与浓度时不发送对应命令，份数未设置时为 1
+	var buf bytes.Buffer
+	page := NewBitmap(17, 2)
+	page.Set(16, 1, true)
+	if err := EncodeZPL(&buf, page, Settings{Width: 17, Height: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "^XA\n" +
+		"^PW17\n" +
+		"^LL2\n" +
+		"^FO0,0^GFA,6,6,3,000000000080^FS\n" +
+		"^PQ1\n" +
+		"^XZ\n"
+	if got := buf.String(); got != want {
+		t.Errorf("EncodeZPL =\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"airprint-service/label"
+)
+
+// labelEncoders 各标签打印机后端使用的指令编码
+var labelEncoders = map[string]label.Encoder{
+	BackendSBPL: label.EncodeSBPL,
+	BackendZPL:  label.EncodeZPL,
+	BackendTSPL: label.EncodeTSPL,
+}
+
+// labelFormats 各标签打印机后端可以原样发送的指令格式（document-format）
+var labelFormats = map[string]string{
+	BackendSBPL: formatSBPL,
+	BackendZPL:  formatZPL,
+}
+
+// LabelPrinterManager 将图像任务转换为标签打印机指令后通过原始 TCP 端口发送的打印机管理器
+//
+// 文档的每一页生成一张标签，图像按配置的留白、旋转与抖动方法渲染到标签尺寸；份数由打印机指令中的数量实现。
+// 已经是打印机指令语言的文档原样发送。
+type LabelPrinterManager struct {
+	*RawPrinterManager
+	labels map[string]labelPrinter
+}
+
+// labelPrinter 一台标签打印机的指令语言与标签设置
+type labelPrinter struct {
+	language string
+	encoder  label.Encoder
+	config   LabelConfig
+	text     TextConfig
+}
+
+// NewLabelPrinterManager 根据打印机配置创建标签打印机管理器，text 为未单独设置时的纯文本排版设置
+func NewLabelPrinterManager(printers map[string]PrinterConfig, text TextConfig) *LabelPrinterManager {
+	m := &LabelPrinterManager{
+		RawPrinterManager: NewRawPrinterManager(printers),
+		labels:            make(map[string]labelPrinter),
+	}
+	for name, printer := range printers {
+		if !label.Dither(printer.Label.Dither).Valid() {
+			log.Printf("标签打印机 %s 的抖动方法 %q 不受支持，使用 threshold", name, printer.Label.Dither)
+		}
+		p := labelPrinter{
+			language: printer.Backend,
+			encoder:  labelEncoders[printer.Backend],
+			config:   printer.Label.withDefaults(),
+			text:     text,
+		}
+		if printer.Text != nil {
+			p.text = *printer.Text
+		}
+		m.labels[name] = p
+	}
+	return m
+}
+
+// GetCapabilities 按标签设置返回打印机能力：单色、单一标签尺寸与分辨率
+func (m *LabelPrinterManager) GetCapabilities(name string) (PrinterCapabilities, error) {
+	p, ok := m.labels[name]
+	if !ok {
+		return PrinterCapabilities{}, fmt.Errorf("unknown label printer %s", name)
+	}
+	media := p.config.mediaName()
+	formats := []string{formatURF, formatPWGRaster, formatPNG, formatJPEG, formatText}
+	if native, ok := labelFormats[p.language]; ok {
+		formats = append(formats, native)
+	}
+	return PrinterCapabilities{
+		MakeAndModel: strings.ToUpper(p.language) + " Label Printer",
+		Resolutions:  []int{p.config.Resolution},
+		Media:        []string{media},
+		DefaultMedia: media,
+		Formats:      formats,
+	}, nil
+}
+
+// mediaName 返回标签尺寸对应的 PWG 自定义媒体名称
+func (l LabelConfig) mediaName() string {
+	w := strconv.FormatFloat(l.Width, 'f', -1, 64)
+	h := strconv.FormatFloat(l.Height, 'f', -1, 64)
+	return fmt.Sprintf("custom_label_%sx%smm", w, h)
+}
+
+// settings 返回任务使用的标签打印设置
+func (p labelPrinter) settings(template JobTemplate) label.Settings {
+	return label.Settings{
+		Width:      p.config.dots(p.config.Width),
+		Height:     p.config.dots(p.config.Height),
+		OffsetX:    p.config.OffsetX,
+		OffsetY:    p.config.OffsetY,
+		Speed:      p.config.Speed,
+		Darkness:   p.config.Darkness,
+		Quantity:   template.Copies,
+		Resolution: p.config.Resolution,
+	}
+}
+
+// renderOptions 返回任务的图像渲染设置，orientation-requested 的旋转叠加在配置的旋转之上
+func (p labelPrinter) renderOptions(template JobTemplate) label.RenderOptions {
+	return label.RenderOptions{
+		Width:     p.config.dots(p.config.Width),
+		Height:    p.config.dots(p.config.Height),
+		Margin:    p.config.dots(p.config.Margin),
+		Rotation:  p.config.Rotate + orientationRotation(template.Orientation),
+		Dither:    label.Dither(p.config.Dither),
+		Threshold: p.config.Threshold,
+	}
+}
+
+// textPage 返回纯文本使用的页面：标签尺寸，旋转 90 度时宽高互换以便旋转后铺满标签
+func (p labelPrinter) textPage(render label.RenderOptions) textPage {
+	width, height := render.Width, render.Height
+	if rotation := (render.Rotation%360 + 360) % 360; rotation == 90 || rotation == 270 {
+		width, height = height, width
+	}
+	return textPage{
+		Width:  width,
+		Height: height,
+		DPI:    p.config.Resolution,
+		Margin: p.config.dots(p.text.Margin),
+		Config: p.text,
+	}
+}
+
+// orientationRotation 返回 orientation-requested 对应的顺时针旋转角度
+func orientationRotation(orientation int) int {
+	switch orientation {
+	case 4: // landscape：内容逆时针旋转 90 度
+		return 270
+	case 5: // reverse-landscape
+		return 90
+	case 6: // reverse-portrait
+		return 180
+	}
+	return 0
+}
+
+// SendJob 将各文档渲染为标签位图并编码为打印机指令，作为一个任务发送
+//
+// 文档全部转换成功后才连接打印机，转换失败时不会打印任何标签。formats 决定各文档的
+// 解码方式，打印机指令语言的文档按份数重复发送。
+func (m *LabelPrinterManager) SendJob(ctx context.Context, name, title string, files, formats []string, template JobTemplate) (string, error) {
+	p, ok := m.labels[name]
+	if !ok {
+		return "", fmt.Errorf("unknown label printer %s", name)
+	}
+	settings := p.settings(template)
+	render := p.renderOptions(template)
+	copies := template.Copies
+	if copies < 1 {
+		copies = 1
+	}
+
+	// 连接打印机之前先检查文档，页面在发送时逐页解码、渲染并写入连接
+	var parts []func(io.Writer) error
+	for i, path := range files {
+		path, format := path, pageFormat(path, formats[i])
+		if native, ok := labelFormats[p.language]; ok && format == native {
+			parts = append(parts, func(w io.Writer) error {
+				for i := 0; i < copies; i++ {
+					if err := copyFile(w, path); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			continue
+		}
+
+		if err := checkDocumentPages(path, format); err != nil {
+			return "", err
+		}
+		parts = append(parts, func(w io.Writer) error {
+			return documentPages(path, format, p.textPage(render), func(img image.Image) error {
+				return p.encoder(w, label.Render(img, render), settings)
+			})
+		})
+	}
+
+	return "", m.printers[name].send(ctx, func(w io.Writer) error {
+		for _, part := range parts {
+			if err := part(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// LPD 协议（RFC 1179）常量
+const (
+	lpdDefaultPort  = "515"
+	lpdDefaultQueue = "lp"
+	lpdUser         = "airprint" // 控制文件中的用户，删除任务时作为 agent
+	lpdProbeTimeout = 2 * time.Second
+)
+
+// LPD 守护进程命令与接收任务子命令
+const (
+	lpdReceiveJob     = 0x02
+	lpdShortQueue     = 0x03
+	lpdRemoveJobs     = 0x05
+	lpdSubControlFile = 0x02
+	lpdSubDataFile    = 0x03
+)
+
+// LPDPrinterManager 通过 LPD 协议（RFC 1179）发送任务的打印机管理器
+//
+// 打印机来自配置文件。RFC 1179 要求客户端使用 721-731 源端口，这里不做限制，
+// 要求特权端口的打印服务器需要放宽该检查。
+type LPDPrinterManager struct {
+	printers map[string]*lpdPrinter
+	host     string
+
+	mu    sync.Mutex
+	jobID int // 下一个任务号，0-999 循环使用
+}
+
+// lpdPrinter 一台 LPD 打印机
+type lpdPrinter struct {
+	name        string
+	description string
+	address     string
+	queue       string
+	timeout     time.Duration
+	formats     []string
+	color       bool
+}
+
+// NewLPDPrinterManager 根据打印机配置创建 LPD 打印机管理器
+func NewLPDPrinterManager(printers map[string]PrinterConfig) *LPDPrinterManager {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "airprint"
+	}
+	// 控制文件中的主机名最长 31 个字符
+	if len(host) > 31 {
+		host = host[:31]
+	}
+
+	m := &LPDPrinterManager{
+		printers: make(map[string]*lpdPrinter),
+		host:     host,
+		jobID:    int(time.Now().Unix() % 1000),
+	}
+	for name, printer := range printers {
+		config := printer.LPD.withDefaults()
+		m.printers[name] = &lpdPrinter{
+			name:        name,
+			description: printer.Description,
+			address:     lpdAddress(printer.Address),
+			queue:       config.Queue,
+			timeout:     time.Duration(config.Timeout) * time.Second,
+			formats:     passthroughFormats(printer.Formats),
+			color:       printer.Color,
+		}
+	}
+	return m
+}
+
+// lpdAddress 为没有端口的地址补上默认端口 515
+func lpdAddress(address string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(address, lpdDefaultPort)
+}
+
+// Printers 返回全部打印机名称
+func (m *LPDPrinterManager) Printers() []string {
+	names := make([]string, 0, len(m.printers))
+	for name := range m.printers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetPrinters 获取所有打印机，查询不到队列状态的打印机状态为 Offline
+func (m *LPDPrinterManager) GetPrinters() ([]PrinterInfo, error) {
+	names := m.Printers()
+	status := m.PrinterStatus(names)
+	printers := make([]PrinterInfo, len(names))
+	for i, name := range names {
+		printers[i] = PrinterInfo{
+			Name:        name,
+			Description: m.printers[name].description,
+			Status:      status[name],
+		}
+		if printers[i].Description == "" {
+			printers[i].Description = name
+		}
+	}
+	return printers, nil
+}
+
+// PrinterStatus 只查询指定打印机的队列状态，返回 Available 或 Offline，未知的打印机不在结果中
+func (m *LPDPrinterManager) PrinterStatus(names []string) map[string]string {
+	status := make(map[string]string)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range names {
+		p, ok := m.printers[name]
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), lpdProbeTimeout)
+			defer cancel()
+			s := "Available"
+			if _, err := p.queueStatus(ctx); err != nil {
+				s = "Offline"
+			}
+			mu.Lock()
+			status[name] = s
+			mu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+	return status
+}
+
+// GetCapabilities 返回配置的打印机能力，文档格式只有 application/octet-stream 与配置的格式
+//
+// 数据文件以 l（原样打印）发送，打印服务器不做转换；分辨率与纸张使用默认值。
+func (m *LPDPrinterManager) GetCapabilities(name string) (PrinterCapabilities, error) {
+	p, ok := m.printers[name]
+	if !ok {
+		return PrinterCapabilities{}, fmt.Errorf("unknown lpd printer %s", name)
+	}
+	return PrinterCapabilities{
+		MakeAndModel: "LPD Printer",
+		Color:        p.color,
+		Formats:      p.formats,
+	}, nil
+}
+
+// GetDefault LPD 打印机没有默认打印机
+func (m *LPDPrinterManager) GetDefault() (string, error) {
+	return "", fmt.Errorf("lpd printers have no default printer")
+}
+
+// SetDefault LPD 打印机不能设为系统默认打印机
+func (m *LPDPrinterManager) SetDefault(name string) error {
+	return fmt.Errorf("lpd printer %s cannot be the system default printer", name)
+}
+
+// Refresh LPD 打印机不需要刷新
+func (m *LPDPrinterManager) Refresh() error {
+	return nil
+}
+
+// QueueStatus 查询打印机队列状态（短格式），返回打印服务器的原始文本
+func (m *LPDPrinterManager) QueueStatus(ctx context.Context, name string) (string, error) {
+	p, ok := m.printers[name]
+	if !ok {
+		return "", fmt.Errorf("unknown lpd printer %s", name)
+	}
+	return p.queueStatus(ctx)
+}
+
+// SendJob 以 receive-job 命令发送控制文件与数据文件，返回 "<打印机>-<任务号>" 形式的任务 ID
+//
+// 数据文件以 l（原样打印）发送，不使用 formats。
+func (m *LPDPrinterManager) SendJob(ctx context.Context, name, title string, files, formats []string, template JobTemplate) (string, error) {
+	p, ok := m.printers[name]
+	if !ok {
+		return "", fmt.Errorf("unknown lpd printer %s", name)
+	}
+	if len(files) > 26 {
+		return "", fmt.Errorf("lpd job cannot contain more than 26 documents")
+	}
+
+	id := m.nextJobID()
+	if err := p.sendJob(ctx, m.host, id, lpdTitle(title), files, template); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%d", name, id), nil
+}
+
+// CancelJob 删除打印服务器队列中的任务，jobID 为 SendJob 返回的任务号部分
+func (m *LPDPrinterManager) CancelJob(name, jobID string) error {
+	p, ok := m.printers[name]
+	if !ok {
+		return fmt.Errorf("unknown lpd printer %s", name)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	return p.removeJob(ctx, jobID)
+}
+
+// nextJobID 分配任务号
+func (m *LPDPrinterManager) nextJobID() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := m.jobID
+	m.jobID = (m.jobID + 1) % 1000
+	return id
+}
+
+// lpdConn 设置了超时与取消的 LPD 连接
+type lpdConn struct {
+	net.Conn
+	timeout time.Duration
+	done    chan struct{}
+}
+
+// dial 连接打印服务器，ctx 结束时中断连接上的读写
+func (p *lpdPrinter) dial(ctx context.Context) (*lpdConn, error) {
+	dialer := net.Dialer{Timeout: p.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", p.address, err)
+	}
+
+	c := &lpdConn{Conn: conn, timeout: p.timeout, done: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now())
+		case <-c.done:
+		}
+	}()
+	return c, nil
+}
+
+// Close 关闭连接
+func (c *lpdConn) Close() error {
+	close(c.done)
+	return c.Conn.Close()
+}
+
+// command 发送一行命令，deadline 从此时起计算
+func (c *lpdConn) command(code byte, operands ...string) error {
+	c.SetDeadline(time.Now().Add(c.timeout))
+	line := append([]byte{code}, strings.Join(operands, " ")...)
+	_, err := c.Write(append(line, '\n'))
+	return err
+}
+
+// ack 读取确认字节，非 0 表示打印服务器拒绝
+func (c *lpdConn) ack(step string) error {
+	c.SetDeadline(time.Now().Add(c.timeout))
+	var b [1]byte
+	if _, err := io.ReadFull(c, b[:]); err != nil {
+		return fmt.Errorf("no acknowledgement for %s: %v", step, err)
+	}
+	if b[0] != 0 {
+		return fmt.Errorf("printer rejected %s (code %d)", step, b[0])
+	}
+	return nil
+}
+
+// sendFile 发送接收任务的一个子命令及其文件内容，内容以 0 字节结束
+func (c *lpdConn) sendFile(code byte, name string, size int64, content io.Reader) error {
+	if err := c.command(code, strconv.FormatInt(size, 10), name); err != nil {
+		return err
+	}
+	if err := c.ack(name); err != nil {
+		return err
+	}
+
+	// 大文件按块写入，每块刷新写超时
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := content.Read(buf)
+		if n > 0 {
+			c.SetDeadline(time.Now().Add(c.timeout))
+			if _, werr := c.Write(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := c.Write([]byte{0}); err != nil {
+		return err
+	}
+	return c.ack(name)
+}
+
+// lpdTitleMax 控制文件中任务名称（J 行）的最大字节数
+const lpdTitleMax = 99
+
+// lpdTitle 将任务名称转换为控制文件中可用的一行：控制字符替换为空格，过长时在字符边界截断，
+// 为空时使用默认名称
+func lpdTitle(name string) string {
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, strings.ToValidUTF8(name, "")))
+	if name == "" {
+		return "AirPrint job"
+	}
+	for len(name) > lpdTitleMax {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	return name
+}
+
+// sendJob 执行 receive-job：先发送控制文件，再依次发送数据文件，title 用作任务名称与文件名称
+func (p *lpdPrinter) sendJob(ctx context.Context, host string, id int, title string, files []string, template JobTemplate) error {
+	copies := template.Copies
+	if copies < 1 {
+		copies = 1
+	}
+
+	suffix := fmt.Sprintf("%03d%s", id, host)
+
+	// 控制文件：每个数据文件按份数重复 l（原样打印）行，打印后由服务器删除
+	var control strings.Builder
+	fmt.Fprintf(&control, "H%s\nP%s\nJ%s\n", host, lpdUser, title)
+	dataNames := make([]string, len(files))
+	for i := range files {
+		dataNames[i] = "df" + string(rune('A'+i)) + suffix
+		for c := 0; c < copies; c++ {
+			fmt.Fprintf(&control, "l%s\n", dataNames[i])
+		}
+		fmt.Fprintf(&control, "U%s\nN%s\n", dataNames[i], title)
+	}
+
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	fail := func(err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed to send job to %s: %v", p.address, err)
+	}
+
+	if err := conn.command(lpdReceiveJob, p.queue); err != nil {
+		return fail(err)
+	}
+	if err := conn.ack("receive job"); err != nil {
+		return fail(err)
+	}
+
+	data := control.String()
+	if err := conn.sendFile(lpdSubControlFile, "cfA"+suffix, int64(len(data)), strings.NewReader(data)); err != nil {
+		return fail(err)
+	}
+	for i, path := range files {
+		if err := p.sendDataFile(conn, dataNames[i], path); err != nil {
+			return fail(err)
+		}
+	}
+	return nil
+}
+
+// sendDataFile 发送一个数据文件
+func (p *lpdPrinter) sendDataFile(conn *lpdConn, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %v", path, err)
+	}
+	return conn.sendFile(lpdSubDataFile, name, info.Size(), bufio.NewReader(f))
+}
+
+// queueStatus 发送短格式队列状态命令，读取服务器关闭连接前的全部输出
+func (p *lpdPrinter) queueStatus(ctx context.Context) (string, error) {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if err := conn.command(lpdShortQueue, p.queue); err != nil {
+		return "", fmt.Errorf("failed to query queue on %s: %v", p.address, err)
+	}
+	status, err := io.ReadAll(conn)
+	if err != nil {
+		return "", fmt.Errorf("failed to read queue status from %s: %v", p.address, err)
+	}
+	return string(status), nil
+}
+
+// removeJob 发送删除任务命令
+func (p *lpdPrinter) removeJob(ctx context.Context, jobID string) error {
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.command(lpdRemoveJobs, p.queue, lpdUser, jobID); err != nil {
+		return fmt.Errorf("failed to remove job %s on %s: %v", jobID, p.address, err)
+	}
+	// 服务器处理完成后关闭连接，输出内容没有统一格式
+	if _, err := io.ReadAll(conn); err != nil {
+		return fmt.Errorf("failed to remove job %s on %s: %v", jobID, p.address, err)
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lpdTestCommand 测试打印服务器收到的一条命令或接收任务子命令
+type lpdTestCommand struct {
+	line string // 命令行，不含换行
+	data string // 子命令的文件内容
+	end  byte   // 文件内容之后的结束字节
+}
+
+// lpdTestServer 按 RFC 1179 应答的 LPD 打印服务器，记录每个连接收到的命令
+type lpdTestServer struct {
+	ln    net.Listener
+	conns chan []lpdTestCommand // 连接关闭时发送该连接收到的命令
+	// reject 大于 0 时以错误码 1 应答第 reject 个确认，之后只读取数据直到连接关闭
+	reject int
+}
+
+// newLPDTestServer 在本机随机端口上启动测试打印服务器
+func newLPDTestServer(t *testing.T, reject int) *lpdTestServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &lpdTestServer{ln: ln, conns: make(chan []lpdTestCommand, 4), reject: reject}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+// serve 接受连接，每个连接处理一条守护进程命令
+func (s *lpdTestServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			s.conns <- s.handle(conn)
+		}()
+	}
+}
+
+// handle 读取守护进程命令；receive-job 之后读取子命令与文件内容，每一步应答确认字节
+func (s *lpdTestServer) handle(conn net.Conn) []lpdTestCommand {
+	r := bufio.NewReader(conn)
+	var commands []lpdTestCommand
+	acks := 0
+	// ack 应答一个确认，被拒绝时返回 false
+	ack := func() bool {
+		acks++
+		if acks == s.reject {
+			conn.Write([]byte{1})
+			return false
+		}
+		conn.Write([]byte{0})
+		return true
+	}
+	// drain 记录拒绝之后客户端仍然发送的数据
+	drain := func() {
+		if rest, _ := io.ReadAll(r); len(rest) > 0 {
+			commands = append(commands, lpdTestCommand{data: string(rest)})
+		}
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return commands
+	}
+	commands = append(commands, lpdTestCommand{line: strings.TrimSuffix(line, "\n")})
+	switch line[0] {
+	case lpdReceiveJob:
+		if !ack() {
+			drain()
+			return commands
+		}
+	case lpdShortQueue:
+		conn.Write([]byte("printer is ready\nno entries\n"))
+		return commands
+	default:
+		return commands
+	}
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return commands
+		}
+		command := lpdTestCommand{line: strings.TrimSuffix(line, "\n")}
+		fields := strings.Fields(command.line[1:])
+		size, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return append(commands, command)
+		}
+		if !ack() {
+			drain()
+			return append(commands, command)
+		}
+		data := make([]byte, size+1)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return append(commands, command)
+		}
+		command.data, command.end = string(data[:size]), data[size]
+		commands = append(commands, command)
+		if !ack() {
+			drain()
+			return commands
+		}
+	}
+}
+
+// wait 等待一个连接关闭，返回该连接收到的命令
+func (s *lpdTestServer) wait(t *testing.T) []lpdTestCommand {
+	t.Helper()
+	select {
+	case commands := <-s.conns:
+		return commands
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the lpd connection")
+		return nil
+	}
+}
+
+// newTestLPDManager 返回连接到测试打印服务器的管理器，主机名与任务号固定
+func newTestLPDManager(s *lpdTestServer) *LPDPrinterManager {
+	m := NewLPDPrinterManager(map[string]PrinterConfig{
+		"lpd": {Address: s.ln.Addr().String(), LPD: LPDConfig{Queue: "labels", Timeout: 5}},
+	})
+	m.host = "testhost"
+	m.jobID = 7
+	return m
+}
+
+func TestLPDSendJob(t *testing.T) {
+	server := newLPDTestServer(t, 0)
+	m := newTestLPDManager(server)
+	first := writeTestFile(t, "first.prn", []byte("first\x00document"))
+	second := writeTestFile(t, "second.prn", []byte("second"))
+
+	id, err := m.SendJob(context.Background(), "lpd", "report\n2024", []string{first, second}, nil, JobTemplate{Copies: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "lpd-7" {
+		t.Errorf("job id = %q, want %q", id, "lpd-7")
+	}
+
+	// 每个数据文件按份数重复 l 行，任务名称中的控制字符替换为空格
+	control := "Htesthost\n" +
+		"Pairprint\n" +
+		"Jreport 2024\n" +
+		"ldfA007testhost\n" +
+		"ldfA007testhost\n" +
+		"UdfA007testhost\n" +
+		"Nreport 2024\n" +
+		"ldfB007testhost\n" +
+		"ldfB007testhost\n" +
+		"UdfB007testhost\n" +
+		"Nreport 2024\n"
+	want := []lpdTestCommand{
+		{line: "\x02labels"},
+		{line: "\x02" + strconv.Itoa(len(control)) + " cfA007testhost", data: control},
+		{line: "\x0314 dfA007testhost", data: "first\x00document"},
+		{line: "\x036 dfB007testhost", data: "second"},
+	}
+	got := server.wait(t)
+	if len(got) != len(want) {
+		t.Fatalf("server received %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("command %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLPDSendJobNextID(t *testing.T) {
+	server := newLPDTestServer(t, 0)
+	m := newTestLPDManager(server)
+	m.jobID = 999
+	path := writeTestFile(t, "job.prn", []byte("x"))
+
+	for _, want := range []string{"lpd-999", "lpd-0"} {
+		id, err := m.SendJob(context.Background(), "lpd", "", []string{path}, nil, JobTemplate{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != want {
+			t.Errorf("job id = %q, want %q", id, want)
+		}
+		commands := server.wait(t)
+		if len(commands) < 2 || !strings.Contains(commands[1].data, "\nJAirPrint job\n") {
+			t.Errorf("control file without default job name: %q", commands)
+		}
+	}
+}
+
+func TestLPDSendJobRejected(t *testing.T) {
+	// 服务器拒绝后客户端不再发送任何数据
+	tests := []struct {
+		name   string
+		reject int
+		want   int // 服务器收到的命令数
+	}{
+		{"receive job", 1, 1},
+		{"control file subcommand", 2, 2},
+		{"control file content", 3, 2},
+		{"data file subcommand", 4, 3},
+		{"data file content", 5, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newLPDTestServer(t, tt.reject)
+			m := newTestLPDManager(server)
+			path := writeTestFile(t, "job.prn", []byte("data"))
+
+			if _, err := m.SendJob(context.Background(), "lpd", "job", []string{path}, nil, JobTemplate{}); err == nil {
+				t.Error("SendJob succeeded although the server rejected it")
+			}
+			got := server.wait(t)
+			if len(got) != tt.want {
+				t.Errorf("server received %q, want %d commands", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLPDSendJobTooManyFiles(t *testing.T) {
+	server := newLPDTestServer(t, 0)
+	m := newTestLPDManager(server)
+	files := make([]string, 27)
+	if _, err := m.SendJob(context.Background(), "lpd", "job", files, nil, JobTemplate{}); err == nil {
+		t.Error("SendJob accepted 27 documents")
+	}
+}
+
+func TestLPDCancelJob(t *testing.T) {
+	server := newLPDTestServer(t, 0)
+	m := newTestLPDManager(server)
+	if err := m.CancelJob("lpd", "7"); err != nil {
+		t.Fatal(err)
+	}
+	got := server.wait(t)
+	if len(got) != 1 || got[0].line != "\x05labels airprint 7" {
+		t.Errorf("server received %q, want remove-jobs command", got)
+	}
+}
+
+func TestLPDQueueStatus(t *testing.T) {
+	server := newLPDTestServer(t, 0)
+	m := newTestLPDManager(server)
+	status, err := m.QueueStatus(context.Background(), "lpd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != "printer is ready\nno entries\n" {
+		t.Errorf("status = %q", status)
+	}
+	if got := server.wait(t); len(got) != 1 || got[0].line != "\x03labels" {
+		t.Errorf("server received %q, want short queue command", got)
+	}
+}
+
+func TestLPDTitle(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"report", "report"},
+		{"  a\tb\r\nc\x7f ", "a b  c"},
+		{"", "AirPrint job"},
+		{"\x00\x01", "AirPrint job"},
+		{"bad\xffutf-8", "badutf-8"},
+		{strings.Repeat("a", 120), strings.Repeat("a", lpdTitleMax)},
+		{strings.Repeat("a", 98) + "打印", strings.Repeat("a", 98)},
+	}
+	for _, tt := range tests {
+		if got := lpdTitle(tt.name); got != tt.want {
+			t.Errorf("lpdTitle(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -22,6 +22,7 @@ type App struct {
 	serviceBtn     *widget.Button
 	statusLabel    *widget.Label
 	serviceLabel   *widget.Label
+	jobLabel       *widget.Label
 	
 	printers        []PrinterInfo
 	selectedPrinter string
@@ -37,11 +38,17 @@ func main() {
 	myWindow := myApp.NewWindow("AirPrint Service Manager")
 	myWindow.Resize(fyne.NewSize(600, 400))
 	
+	// 读取服务配置
+	config, err := LoadConfig(DefaultConfigPath())
+	if err != nil {
+		log.Printf("Failed to load config, using defaults: %v", err)
+	}
+
 	// 创建应用实例
-	printerManager := NewPrinterManager()
+	airprintServer := NewAirPrintServer(NewPrinterManager(), config)
 	appInstance := &App{
-		printerManager: printerManager,
-		airprintServer: NewAirPrintServer(printerManager),
+		printerManager: airprintServer.PrinterManager(),
+		airprintServer: airprintServer,
 	}
 	
 	// 初始化 UI
@@ -61,6 +68,10 @@ func (a *App) setupUI(myWindow fyne.Window) {
 	
 	// AirPrint 服务状态标签
 	a.serviceLabel = widget.NewLabel("AirPrint Service: Stopped")
+
+	// 最近打印任务标签，随任务状态变化更新
+	a.jobLabel = widget.NewLabel("No print jobs")
+	go a.watchJobs()
 	
 	// 刷新按钮
 	a.refreshBtn = widget.NewButton("Refresh Printers", func() {
@@ -140,6 +151,7 @@ func (a *App) setupUI(myWindow fyne.Window) {
 	topContainer := container.NewVBox(
 		a.statusLabel,
 		a.serviceLabel,
+		a.jobLabel,
 	)
 	
 	// 主内容容器
@@ -154,6 +166,15 @@ func (a *App) setupUI(myWindow fyne.Window) {
 	myWindow.SetContent(content)
 }
 
+// watchJobs 订阅任务变化并显示最近一次变化
+func (a *App) watchJobs() {
+	events, _ := a.airprintServer.Jobs().Subscribe()
+	for event := range events {
+		a.jobLabel.SetText(fmt.Sprintf("Job %d (%s) on %s: %s",
+			event.JobID, event.Name, event.PrinterName, event.State))
+	}
+}
+
 func (a *App) refreshPrinters() {
 	a.statusLabel.SetText("Refreshing printer list...")
 	a.setDefaultBtn.Disable()
@@ -237,12 +258,12 @@ func (a *App) toggleAirPrintService() {
 		
 		a.serviceRunning = true
 		a.serviceBtn.SetText("Stop AirPrint Service")
-		a.serviceLabel.SetText("AirPrint Service: Running (Port: 8082)")
+		a.serviceLabel.SetText(fmt.Sprintf("AirPrint Service: Running (Port: %d)", a.airprintServer.Port()))
 		
 		// 获取本机 IP
 		if localIP, err := getLocalIP(); err == nil {
 			dialog.ShowInformation("Service Started", 
-				fmt.Sprintf("AirPrint service started\nAccess: http://%s:8082", localIP.String()), 
+				fmt.Sprintf("AirPrint service started\nAccess: http://%s:%d", localIP.String(), a.airprintServer.Port()), 
 				a.window)
 		} else {
 			dialog.ShowInformation("Service Started", "AirPrint service has been started", a.window)
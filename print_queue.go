@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrQueueFull 队列中等待的任务数已达到容量
+var ErrQueueFull = errors.New("print queue is full")
+
+// queuedJob 队列中等待执行的任务
+type queuedJob struct {
+	job      *PrintJob
+	id       int
+	priority int
+	ctx      context.Context
+}
+
+// PrintQueue 单台目标打印机的任务队列
+//
+// 任务按 job-priority 从高到低、同优先级按提交顺序执行，同时执行的任务数不超过 concurrency。
+type PrintQueue struct {
+	name        string
+	concurrency int
+	capacity    int
+	execute     func(ctx context.Context, job *PrintJob)
+
+	mu      sync.Mutex
+	waiting []queuedJob
+	running int
+	stopped string        // 非空时队列已停止，值为 printer-state-reasons 关键字
+	resumed chan struct{} // 队列恢复时关闭
+}
+
+// NewPrintQueue 创建任务队列，capacity 为 0 表示不限制等待的任务数
+func NewPrintQueue(name string, concurrency, capacity int, execute func(ctx context.Context, job *PrintJob)) *PrintQueue {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &PrintQueue{
+		name:        name,
+		concurrency: concurrency,
+		capacity:    capacity,
+		execute:     execute,
+	}
+}
+
+// Full 判断队列是否已满，已满时不再接受新任务
+func (q *PrintQueue) Full() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.capacity > 0 && len(q.waiting) >= q.capacity
+}
+
+// Len 返回等待中与执行中的任务数
+func (q *PrintQueue) Len() (waiting, running int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.waiting), q.running
+}
+
+// Stop 停止队列：不再开始新的任务，直到调用 Resume
+//
+// reason 为 printer-state-reasons 关键字，如操作员暂停时为 "paused"。
+func (q *PrintQueue) Stop(reason string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.stopped == "" {
+		q.resumed = make(chan struct{})
+	}
+	q.stopped = reason
+}
+
+// Resume 恢复已停止的队列，等待中的任务继续执行
+func (q *PrintQueue) Resume() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.stopped == "" {
+		return
+	}
+	q.stopped = ""
+	close(q.resumed)
+	q.dispatchLocked()
+}
+
+// Stopped 返回队列停止的原因，未停止时为空字符串
+func (q *PrintQueue) Stopped() string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.stopped
+}
+
+// WaitResumed 等待队列恢复，ctx 先结束时返回 false
+func (q *PrintQueue) WaitResumed(ctx context.Context) bool {
+	q.mu.Lock()
+	if q.stopped == "" {
+		q.mu.Unlock()
+		return true
+	}
+	resumed := q.resumed
+	q.mu.Unlock()
+
+	select {
+	case <-resumed:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Push 将新接受的任务加入队列，有空闲的执行槽位时立即开始执行；队列已满时返回 ErrQueueFull
+//
+// 容量与入队在同一次加锁中完成，并发提交的任务不会超出容量。任务已在队列中等待时
+// 替换原有的条目，同一任务在队列中只有一个条目。
+func (q *PrintQueue) Push(ctx context.Context, job *PrintJob, id, priority int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if !q.removeLocked(job) && q.capacity > 0 && len(q.waiting) >= q.capacity {
+		return ErrQueueFull
+	}
+	q.insertLocked(ctx, job, id, priority)
+	return nil
+}
+
+// Requeue 将已接受的任务（如服务重启前未完成的任务）加入队列，不受容量限制
+func (q *PrintQueue) Requeue(ctx context.Context, job *PrintJob, id, priority int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.removeLocked(job)
+	q.insertLocked(ctx, job, id, priority)
+}
+
+// insertLocked 按优先级插入任务并尝试开始执行（调用方需持有 mu）
+func (q *PrintQueue) insertLocked(ctx context.Context, job *PrintJob, id, priority int) {
+	item := queuedJob{job: job, id: id, priority: priority, ctx: ctx}
+	i := sort.Search(len(q.waiting), func(i int) bool {
+		w := q.waiting[i]
+		return w.priority < priority || (w.priority == priority && w.id > id)
+	})
+	q.waiting = append(q.waiting, queuedJob{})
+	copy(q.waiting[i+1:], q.waiting[i:])
+	q.waiting[i] = item
+
+	q.dispatchLocked()
+}
+
+// Remove 删除任务等待执行的条目，任务被挂起或取消后不再占用队列容量；
+// 正在执行的任务不受影响，返回是否删除了条目
+func (q *PrintQueue) Remove(job *PrintJob) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.removeLocked(job)
+}
+
+// removeLocked 删除任务等待执行的条目（调用方需持有 mu）
+func (q *PrintQueue) removeLocked(job *PrintJob) bool {
+	for i, item := range q.waiting {
+		if item.job == job {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// dispatchLocked 在执行槽位未满时取出队首任务执行（调用方需持有 mu）
+func (q *PrintQueue) dispatchLocked() {
+	for q.stopped == "" && q.running < q.concurrency && len(q.waiting) > 0 {
+		item := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		q.running++
+
+		go func() {
+			q.execute(item.ctx, item.job)
+
+			q.mu.Lock()
+			q.running--
+			q.dispatchLocked()
+			q.mu.Unlock()
+		}()
+	}
+}
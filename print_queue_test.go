@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestPrintQueuePushCapacity(t *testing.T) {
+	q := NewPrintQueue("test", 1, 3, func(ctx context.Context, job *PrintJob) {})
+	// 停止的队列不开始执行任务，入队的任务全部等待
+	q.Stop("paused")
+
+	jobs := make([]*PrintJob, 20)
+	errs := make([]error, len(jobs))
+	var wg sync.WaitGroup
+	for i := range jobs {
+		jobs[i] = &PrintJob{ID: i + 1}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = q.Push(context.Background(), jobs[i], jobs[i].ID, defaultJobPriority)
+		}(i)
+	}
+	wg.Wait()
+
+	accepted := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			accepted++
+		case !errors.Is(err, ErrQueueFull):
+			t.Errorf("Push error = %v, want ErrQueueFull", err)
+		}
+	}
+	if accepted != 3 {
+		t.Errorf("%d concurrent jobs accepted, want 3", accepted)
+	}
+	if waiting, _ := q.Len(); waiting != 3 {
+		t.Errorf("%d jobs waiting, want 3", waiting)
+	}
+	if !q.Full() {
+		t.Error("queue at capacity not reported full")
+	}
+}
+
+func TestPrintQueueReplaceAndRequeue(t *testing.T) {
+	q := NewPrintQueue("test", 1, 2, func(ctx context.Context, job *PrintJob) {})
+	q.Stop("paused")
+
+	first, second := &PrintJob{ID: 1}, &PrintJob{ID: 2}
+	for _, job := range []*PrintJob{first, second} {
+		if err := q.Push(context.Background(), job, job.ID, defaultJobPriority); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 已在队列中等待的任务替换原有条目，不受容量限制
+	if err := q.Push(context.Background(), first, first.ID, 100); err != nil {
+		t.Errorf("Push of a waiting job = %v, want nil", err)
+	}
+	if err := q.Push(context.Background(), &PrintJob{ID: 3}, 3, defaultJobPriority); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Push to a full queue = %v, want ErrQueueFull", err)
+	}
+
+	// 已接受的任务总能入队
+	q.Requeue(context.Background(), &PrintJob{ID: 4}, 4, defaultJobPriority)
+	if waiting, _ := q.Len(); waiting != 3 {
+		t.Errorf("%d jobs waiting, want 3", waiting)
+	}
+
+	// 删除等待的任务后可以再次接受
+	q.Remove(second)
+	q.Remove(first)
+	if err := q.Push(context.Background(), &PrintJob{ID: 5}, 5, defaultJobPriority); err != nil {
+		t.Errorf("Push after removing jobs = %v, want nil", err)
+	}
+}
+
+func TestPrintQueueUnlimited(t *testing.T) {
+	q := NewPrintQueue("test", 1, 0, func(ctx context.Context, job *PrintJob) {})
+	q.Stop("paused")
+	for i := 1; i <= 100; i++ {
+		if err := q.Push(context.Background(), &PrintJob{ID: i}, i, defaultJobPriority); err != nil {
+			t.Fatalf("Push %d to an unlimited queue = %v", i, err)
+		}
+	}
+	if q.Full() {
+		t.Error("unlimited queue reported full")
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// JobSender 能够不经过系统打印队列直接发送任务的打印机管理器
+type JobSender interface {
+	// SendJob 将文件作为一个任务发送到打印机，title 为任务名称（job-name），formats 为各文件的
+	// 文档格式，返回打印机侧的任务 ID（没有时为空字符串）
+	SendJob(ctx context.Context, name, title string, files, formats []string, template JobTemplate) (string, error)
+}
+
+// PermanentError 重试或改用其他打印机也不会成功的打印错误，如文档无法解码或格式不受支持
+//
+// 后端返回此类错误时任务立即中止，不按重试策略重试，也不停止打印机队列。
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// permanentError 将 err 标记为不可重试的错误
+func permanentError(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// isPermanent 判断错误是否不可重试
+func isPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// JobCanceler 能够取消已发送到打印机的任务的打印机管理器
+type JobCanceler interface {
+	// CancelJob 取消任务，jobID 为 SendJob 返回的任务 ID 中打印机名称之后的部分
+	CancelJob(name, jobID string) error
+}
+
+// StatusProvider 能够只查询指定打印机状态的打印机管理器
+//
+// 网络后端查询状态需要连接打印机，只查询需要的打印机可以避免等待其他打印机的连接超时。
+type StatusProvider interface {
+	// PrinterStatus 返回各打印机的状态（同 PrinterInfo.Status），查询不到的打印机不在结果中
+	PrinterStatus(names []string) map[string]string
+}
+
+// BackendManager 合并系统打印机与配置文件中使用其他后端的打印机
+//
+// 默认打印机的查询与设置只对系统打印机有效；同名时配置文件中的打印机优先。
+type BackendManager struct {
+	system PrinterManager
+	owners map[string]PrinterManager // 非系统后端的打印机名称 -> 所属后端
+}
+
+// NewBackendManager 在系统打印机管理器之外加入配置文件中的原始 TCP 等打印机
+func NewBackendManager(system PrinterManager, config Config) *BackendManager {
+	m := &BackendManager{
+		system: system,
+		owners: make(map[string]PrinterManager),
+	}
+
+	raw := NewRawPrinterManager(config.backendPrinters(BackendRaw))
+	for _, name := range raw.Printers() {
+		m.owners[name] = raw
+	}
+	lpd := NewLPDPrinterManager(config.backendPrinters(BackendLPD))
+	for _, name := range lpd.Printers() {
+		m.owners[name] = lpd
+	}
+	labels := NewLabelPrinterManager(config.labelPrinters(), config.Text)
+	for _, name := range labels.Printers() {
+		m.owners[name] = labels
+	}
+
+	for name, printer := range config.Printers {
+		switch printer.Backend {
+		case "", BackendSystem, BackendRaw, BackendLPD, BackendSBPL, BackendZPL, BackendTSPL:
+		default:
+			log.Printf("打印机 %s 的后端 %q 不受支持，使用系统打印队列", name, printer.Backend)
+		}
+	}
+	return m
+}
+
+// backends 返回全部非系统后端，每个后端只出现一次
+func (m *BackendManager) backends() []PrinterManager {
+	var backends []PrinterManager
+	seen := make(map[PrinterManager]bool)
+	for _, backend := range m.owners {
+		if !seen[backend] {
+			seen[backend] = true
+			backends = append(backends, backend)
+		}
+	}
+	return backends
+}
+
+// owner 返回打印机所属的后端
+func (m *BackendManager) owner(name string) PrinterManager {
+	if backend, ok := m.owners[name]; ok {
+		return backend
+	}
+	return m.system
+}
+
+// GetPrinters 获取所有打印机，系统打印机在前
+func (m *BackendManager) GetPrinters() ([]PrinterInfo, error) {
+	system, err := m.system.GetPrinters()
+	if err != nil && len(m.owners) == 0 {
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("获取系统打印机列表失败: %v", err)
+	}
+
+	var printers []PrinterInfo
+	for _, p := range system {
+		if _, ok := m.owners[p.Name]; !ok {
+			printers = append(printers, p)
+		}
+	}
+	for _, backend := range m.backends() {
+		list, err := backend.GetPrinters()
+		if err != nil {
+			log.Printf("获取打印机列表失败: %v", err)
+			continue
+		}
+		printers = append(printers, list...)
+	}
+	return printers, nil
+}
+
+// PrinterStatus 只查询指定打印机的状态：网络后端的打印机由所属后端分别查询，
+// 系统打印机共用一次系统打印机列表查询
+func (m *BackendManager) PrinterStatus(names []string) map[string]string {
+	status := make(map[string]string)
+	byBackend := make(map[PrinterManager][]string)
+	var system []string
+	for _, name := range names {
+		if backend, ok := m.owners[name]; ok {
+			byBackend[backend] = append(byBackend[backend], name)
+		} else {
+			system = append(system, name)
+		}
+	}
+
+	for backend, list := range byBackend {
+		for name, s := range backendStatus(backend, list) {
+			status[name] = s
+		}
+	}
+	if len(system) > 0 {
+		for name, s := range backendStatus(m.system, system) {
+			status[name] = s
+		}
+	}
+	return status
+}
+
+// backendStatus 查询后端中指定打印机的状态，后端不支持单独查询时从完整的打印机列表中选取
+func backendStatus(backend PrinterManager, names []string) map[string]string {
+	if provider, ok := backend.(StatusProvider); ok {
+		return provider.PrinterStatus(names)
+	}
+
+	status := make(map[string]string)
+	printers, err := backend.GetPrinters()
+	if err != nil {
+		log.Printf("获取打印机列表失败: %v", err)
+		return status
+	}
+	for _, p := range printers {
+		if containsString(names, p.Name) {
+			status[p.Name] = p.Status
+		}
+	}
+	return status
+}
+
+// GetDefault 获取系统默认打印机
+func (m *BackendManager) GetDefault() (string, error) {
+	return m.system.GetDefault()
+}
+
+// SetDefault 设置默认打印机
+func (m *BackendManager) SetDefault(name string) error {
+	return m.owner(name).SetDefault(name)
+}
+
+// Refresh 刷新全部后端
+func (m *BackendManager) Refresh() error {
+	err := m.system.Refresh()
+	for _, backend := range m.backends() {
+		if e := backend.Refresh(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// GetCapabilities 从打印机所属的后端查询打印机能力
+func (m *BackendManager) GetCapabilities(name string) (PrinterCapabilities, error) {
+	provider, ok := m.owner(name).(CapabilityProvider)
+	if !ok {
+		return PrinterCapabilities{}, fmt.Errorf("printer %s does not report capabilities", name)
+	}
+	return provider.GetCapabilities(name)
+}
+
+// CancelJob 取消已发送的任务，remoteID 为 "<打印机>-<任务号>" 形式的任务 ID
+//
+// 所属后端不支持取消时交给系统打印队列取消。
+func (m *BackendManager) CancelJob(remoteID string) error {
+	if i := strings.LastIndex(remoteID, "-"); i > 0 {
+		name := remoteID[:i]
+		if backend, ok := m.owners[name]; ok {
+			canceler, ok := backend.(JobCanceler)
+			if !ok {
+				return fmt.Errorf("printer %s cannot cancel jobs", name)
+			}
+			return canceler.CancelJob(name, remoteID[i+1:])
+		}
+	}
+	return cancelSystemJob(remoteID)
+}
+
+// Sender 返回直接发送任务的后端，系统打印机返回 nil
+func (m *BackendManager) Sender(name string) JobSender {
+	sender, _ := m.owners[name].(JobSender)
+	return sender
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 打印机类的成员选择策略
+const (
+	StrategyRoundRobin = "round-robin" // 依次轮流
+	StrategyLeastBusy  = "least-busy"  // 正在打印的任务最少的成员
+	StrategyFirstIdle  = "first-idle"  // 按顺序第一个空闲的成员，都不空闲时同 least-busy
+)
+
+// ClassConfig 打印机类（打印机池）配置
+type ClassConfig struct {
+	// Members 成员打印机的打印队列名称
+	Members []string `json:"members"`
+	// Strategy 成员选择策略，默认为 round-robin
+	Strategy string `json:"strategy"`
+	// Description 显示名称，为空时使用类名
+	Description string `json:"description"`
+}
+
+// PrinterClassManager 在 PrinterManager 之上提供打印机类
+//
+// 每个类作为一台打印机出现在打印机列表中，打印时按选择策略分配给一个可用的成员。
+type PrinterClassManager struct {
+	PrinterManager
+	classes map[string]ClassConfig
+
+	mu   sync.Mutex
+	next map[string]int // 各类 round-robin 的下一个位置
+	busy map[string]int // 各成员正在打印的任务数
+}
+
+// NewPrinterClassManager 创建打印机类管理器，没有成员的类会被忽略
+func NewPrinterClassManager(manager PrinterManager, classes map[string]ClassConfig) *PrinterClassManager {
+	valid := make(map[string]ClassConfig)
+	for name, class := range classes {
+		if len(class.Members) == 0 {
+			continue
+		}
+		if class.Strategy == "" {
+			class.Strategy = StrategyRoundRobin
+		}
+		valid[name] = class
+	}
+	return &PrinterClassManager{
+		PrinterManager: manager,
+		classes:        valid,
+		next:           make(map[string]int),
+		busy:           make(map[string]int),
+	}
+}
+
+// GetPrinters 获取所有打印机，各打印机类附加在物理打印机之后
+//
+// 打印机类的状态在至少一个成员可用时为 Available。
+func (m *PrinterClassManager) GetPrinters() ([]PrinterInfo, error) {
+	printers, err := m.PrinterManager.GetPrinters()
+	if err != nil && len(m.classes) == 0 {
+		return nil, err
+	}
+
+	status := make(map[string]string)
+	for _, p := range printers {
+		status[p.Name] = p.Status
+	}
+
+	var names []string
+	for name := range m.classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		class := m.classes[name]
+		info := PrinterInfo{
+			Name:        name,
+			Description: class.Description,
+			Status:      "Unavailable",
+		}
+		if info.Description == "" {
+			info.Description = name
+		}
+		for _, member := range class.Members {
+			if s, ok := status[member]; ok && printerAvailable(s) {
+				info.Status = "Available"
+				break
+			}
+		}
+		printers = append(printers, info)
+	}
+	return printers, nil
+}
+
+// SetDefault 设置默认打印机，打印机类不能设为系统默认打印机
+func (m *PrinterClassManager) SetDefault(name string) error {
+	if _, ok := m.classes[name]; ok {
+		return fmt.Errorf("printer class %s cannot be the system default printer", name)
+	}
+	return m.PrinterManager.SetDefault(name)
+}
+
+// GetCapabilities 获取打印机能力，打印机类使用第一个成员的能力
+func (m *PrinterClassManager) GetCapabilities(name string) (PrinterCapabilities, error) {
+	provider, ok := m.PrinterManager.(CapabilityProvider)
+	if !ok {
+		return PrinterCapabilities{}, fmt.Errorf("printer manager does not report capabilities")
+	}
+	if class, ok := m.classes[name]; ok {
+		return provider.GetCapabilities(class.Members[0])
+	}
+	return provider.GetCapabilities(name)
+}
+
+// IsClass 判断名称是否为打印机类
+func (m *PrinterClassManager) IsClass(name string) bool {
+	_, ok := m.classes[name]
+	return ok
+}
+
+// ClassConcurrency 返回打印机类的成员数，用作类队列的默认并发数
+func (m *PrinterClassManager) ClassConcurrency(name string) int {
+	return len(m.classes[name].Members)
+}
+
+// Select 按选择策略为打印机类排列可用成员，第一个为本次选中的成员，其余作为失败时的备用
+//
+// name 不是打印机类时原样返回。
+func (m *PrinterClassManager) Select(name string) ([]string, error) {
+	class, ok := m.classes[name]
+	if !ok {
+		return []string{name}, nil
+	}
+
+	// 跳过状态不可用的成员，只查询本类成员的状态
+	status := backendStatus(m.PrinterManager, class.Members)
+	var members []string
+	for _, member := range class.Members {
+		if s, ok := status[member]; ok && printerAvailable(s) {
+			members = append(members, member)
+		}
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("no available member in printer class %s", name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	first := 0
+	switch class.Strategy {
+	case StrategyLeastBusy:
+		first = m.leastBusyLocked(members)
+	case StrategyFirstIdle:
+		first = -1
+		for i, member := range members {
+			if m.busy[member] == 0 {
+				first = i
+				break
+			}
+		}
+		if first < 0 {
+			first = m.leastBusyLocked(members)
+		}
+	default:
+		first = m.next[name] % len(members)
+		m.next[name] = first + 1
+	}
+
+	// 选中的成员在前，其余成员按顺序排在后面
+	return append(append([]string(nil), members[first:]...), members[:first]...), nil
+}
+
+// leastBusyLocked 返回正在打印的任务最少的成员位置（调用方需持有 mu）
+func (m *PrinterClassManager) leastBusyLocked(members []string) int {
+	best := 0
+	for i, member := range members {
+		if m.busy[member] < m.busy[members[best]] {
+			best = i
+		}
+	}
+	return best
+}
+
+// Begin 记录成员开始打印一个任务
+func (m *PrinterClassManager) Begin(member string) {
+	m.mu.Lock()
+	m.busy[member]++
+	m.mu.Unlock()
+}
+
+// Done 记录成员结束打印一个任务
+func (m *PrinterClassManager) Done(member string) {
+	m.mu.Lock()
+	if m.busy[member] > 0 {
+		m.busy[member]--
+	}
+	m.mu.Unlock()
+}
+
+// printerAvailable 根据 PrinterInfo.Status 判断打印机能否接受任务
+func printerAvailable(status string) bool {
+	switch strings.ToLower(status) {
+	case "disabled", "offline", "unavailable", "error", "stopped", "rejecting":
+		return false
+	}
+	return true
+}
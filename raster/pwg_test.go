@@ -0,0 +1,149 @@
+package raster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"testing"
+)
+
+// pwgPageHeader 返回 PWG Raster 页头
+func pwgPageHeader(colorSpace, bitsPerColor, bitsPerPixel, width, height, dpi int) []byte {
+	hdr := make([]byte, pwgHeaderSize)
+	copy(hdr, "PwgRaster")
+	put := func(offset, v int) { binary.BigEndian.PutUint32(hdr[offset:], uint32(v)) }
+	put(pwgHWResolution, dpi)
+	put(pwgHWResolution+4, dpi)
+	put(pwgWidth, width)
+	put(pwgHeight, height)
+	put(pwgBitsPerColor, bitsPerColor)
+	put(pwgBitsPerPixel, bitsPerPixel)
+	put(pwgBytesPerLine, (width*bitsPerPixel+7)/8)
+	put(pwgColorSpace, colorSpace)
+	return hdr
+}
+
+// pwgStream 返回包含给定页面的 PWG Raster 数据流
+func pwgStream(pages ...[]byte) []byte {
+	data := []byte(pwgSync)
+	for _, page := range pages {
+		data = append(data, page...)
+	}
+	return data
+}
+
+// black1Rows 返回 1 位黑度页面的像素行与对应的灰度图（置位的点为黑）
+func black1Rows(width, height int) (*image.Gray, [][]byte) {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	rows := make([][]byte, height)
+	for y := 0; y < height; y++ {
+		row := make([]byte, (width+7)/8)
+		for x := 0; x < width; x++ {
+			v := uint8(0xff)
+			if testPattern(x, y, width) < 0x80 {
+				v = 0
+				row[x/8] |= 0x80 >> uint(x%8)
+			}
+			img.Pix[y*img.Stride+x] = v
+		}
+		rows[y] = row
+	}
+	return img, rows
+}
+
+// testPWGPages 返回 sGray 8 位、sRGB 24 位与 black 1 位三页的 PWG Raster 数据流及其原图
+func testPWGPages() ([]byte, []image.Image) {
+	gray, grayLines := grayRows(41, 30)
+	rgb, rgbLines := rgbRows(23, 270)
+	black, blackLines := black1Rows(53, 17)
+	return pwgStream(
+		append(pwgPageHeader(pwgSGray, 8, 8, 41, 30, 300), encodeLines(grayLines, 1, 0xff)...),
+		append(pwgPageHeader(pwgSRGB, 8, 24, 23, 270, 600), encodeLines(rgbLines, 3, 0xff)...),
+		append(pwgPageHeader(pwgBlack, 1, 1, 53, 17, 203), encodeLines(blackLines, 1, 0x00)...),
+	), []image.Image{gray, rgb, black}
+}
+
+func TestPWGRoundTrip(t *testing.T) {
+	data, want := testPWGPages()
+	d, err := NewPWGDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dpis := []int{300, 600, 203}
+	for i, w := range want {
+		page, err := d.NextPage()
+		if err != nil {
+			t.Fatalf("page %d: %v", i+1, err)
+		}
+		if page.XDPI != dpis[i] || page.YDPI != dpis[i] {
+			t.Errorf("page %d: dpi = %dx%d, want %d", i+1, page.XDPI, page.YDPI, dpis[i])
+		}
+		comparePages(t, page.Image, w)
+	}
+	if _, err := d.NextPage(); err != io.EOF {
+		t.Errorf("NextPage after last page = %v, want io.EOF", err)
+	}
+}
+
+func TestPWGBlack8(t *testing.T) {
+	// 8 位黑度取反为亮度，行尾 128 填充白色（黑度 0）
+	line := []byte{0, 254, 0x00, 0x80, 0xff, 128}
+	data := pwgStream(append(pwgPageHeader(pwgBlack, 8, 8, 6, 1, 300), line...))
+	pages, err := DecodePWG(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := pages[0].Image.(*image.Gray).Pix
+	if want := []byte{0xff, 0x7f, 0x00, 0xff, 0xff, 0xff}; !bytes.Equal(got, want) {
+		t.Errorf("pixels = %#v, want %#v", got, want)
+	}
+}
+
+func TestPWGTruncated(t *testing.T) {
+	data, _ := testPWGPages()
+	for _, n := range []int{4 + 100, 4 + pwgHeaderSize + 9, len(data) - 1} {
+		_, err := DecodePWG(bytes.NewReader(data[:n]))
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%d bytes: err = %v, want io.ErrUnexpectedEOF", n, err)
+		}
+	}
+}
+
+func TestPWGInvalidHeader(t *testing.T) {
+	mismatched := pwgPageHeader(pwgSGray, 8, 8, 10, 10, 300)
+	binary.BigEndian.PutUint32(mismatched[pwgBytesPerLine:], 11)
+	banded := pwgPageHeader(pwgSRGB, 8, 24, 10, 10, 300)
+	binary.BigEndian.PutUint32(banded[pwgColorOrder:], 1)
+
+	tests := []struct {
+		name string
+		hdr  []byte
+		want error
+	}{
+		{"oversized", pwgPageHeader(pwgSRGB, 16, 48, 1<<15, 1<<15, 300), ErrPageTooLarge},
+		// 1 位黑度每行只有 1 KB，解码后的灰度图像超过 64 MiB
+		{"oversized 1-bit", pwgPageHeader(pwgBlack, 1, 1, 8192, 8193, 300), ErrPageTooLarge},
+		{"oversized height", pwgPageHeader(pwgSGray, 8, 8, 1, 1<<30, 300), ErrPageTooLarge},
+		{"bytes per line", mismatched, nil},
+		{"color order", banded, nil},
+		{"unsupported color space", pwgPageHeader(6, 8, 32, 10, 10, 300), nil},
+		{"unsupported bits per pixel", pwgPageHeader(pwgSGray, 1, 1, 10, 10, 300), nil},
+	}
+	for _, tt := range tests {
+		_, err := DecodePWG(bytes.NewReader(pwgStream(tt.hdr)))
+		if err == nil {
+			t.Errorf("%s: decoded without error", tt.name)
+			continue
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := NewPWGDecoder(bytes.NewReader([]byte(urfMagic))); err == nil {
+		t.Error("NewPWGDecoder accepted a stream without the PWG sync word")
+	}
+}
@@ -0,0 +1,200 @@
+// Package raster 解码 AirPrint 与 IPP Everywhere 客户端发送的光栅文档（Apple URF 与 PWG Raster）。
+package raster
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// MaxPageBytes 单页解码后页面图像的最大字节数（灰度每像素 1 字节，RGB 每像素 4 字节）
+//
+// 压缩行可以用很少的数据声明巨大的页面，限制页面图像大小防止几 KB 的文档耗尽内存；
+// 64 MiB 足够 300 dpi 的 A4 彩色页面与 600 dpi 的 A4 灰度页面。
+const MaxPageBytes = 64 << 20
+
+// ErrPageTooLarge 页面尺寸超过 MaxPageBytes
+var ErrPageTooLarge = errors.New("raster: page too large")
+
+// Page 解码后的一页
+type Page struct {
+	Image image.Image // *image.Gray 或 *image.RGBA
+	XDPI  int
+	YDPI  int
+}
+
+// colorModel 页面像素的颜色模型
+type colorModel int
+
+const (
+	modelGray  colorModel = iota // 亮度，0 为黑
+	modelBlack                   // 黑度，0 为白
+	modelRGB
+)
+
+// pixelFormat 压缩行中的像素格式
+type pixelFormat struct {
+	model        colorModel
+	bitsPerPixel int // 1、8、16（灰度/黑度）或 24、48（RGB）
+}
+
+// unit 返回压缩的单位字节数：每像素不足 8 位时以字节为单位
+func (f pixelFormat) unit() int {
+	if f.bitsPerPixel < 8 {
+		return 1
+	}
+	return f.bitsPerPixel / 8
+}
+
+// fill 返回背景（白色）的字节值
+func (f pixelFormat) fill() byte {
+	if f.model == modelBlack {
+		return 0x00
+	}
+	return 0xff
+}
+
+// lineBytes 返回一行的字节数
+func (f pixelFormat) lineBytes(width int) int {
+	return (width*f.bitsPerPixel + 7) / 8
+}
+
+// newPageImage 创建页面图像，检查尺寸是否合理
+func newPageImage(width, height int, f pixelFormat) (image.Image, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("raster: invalid page size %dx%d", width, height)
+	}
+	pixelBytes := int64(1)
+	if f.model == modelRGB {
+		pixelBytes = 4
+	}
+	// 先分别检查宽高，避免乘积溢出
+	if int64(width) > MaxPageBytes || int64(height) > MaxPageBytes ||
+		int64(width)*int64(height)*pixelBytes > MaxPageBytes {
+		return nil, ErrPageTooLarge
+	}
+	rect := image.Rect(0, 0, width, height)
+	if f.model == modelRGB {
+		return image.NewRGBA(rect), nil
+	}
+	return image.NewGray(rect), nil
+}
+
+// setRow 将一行像素写入页面图像
+//
+// 16 位通道取高字节（大端序），黑度取反为亮度，1 位黑度中置位的点为黑点。
+func setRow(img image.Image, y int, row []byte, f pixelFormat) {
+	switch img := img.(type) {
+	case *image.Gray:
+		line := img.Pix[y*img.Stride : y*img.Stride+img.Rect.Dx()]
+		if f.bitsPerPixel == 1 {
+			for x := range line {
+				line[x] = 0xff
+				if row[x/8]&(0x80>>uint(x%8)) != 0 {
+					line[x] = 0
+				}
+			}
+			return
+		}
+		step := f.bitsPerPixel / 8
+		for x := range line {
+			v := row[x*step]
+			if f.model == modelBlack {
+				v = ^v
+			}
+			line[x] = v
+		}
+	case *image.RGBA:
+		step := f.bitsPerPixel / 8
+		channel := step / 3
+		for x := 0; x < img.Rect.Dx(); x++ {
+			p := row[x*step:]
+			img.SetRGBA(x, y, color.RGBA{R: p[0], G: p[channel], B: p[2*channel], A: 0xff})
+		}
+	}
+}
+
+// readLine 读取一行 PackBits 风格压缩的像素数据（URF 与 PWG Raster 共用）
+//
+// 压缩以 unit 字节为一个单位（通常为一个像素）：控制字节 n 为 0-127 时下一个单位重复
+// n+1 次，129-255 时随后 257-n 个单位原样复制，128 表示本行剩余部分填充背景色 fill。
+func readLine(r io.ByteReader, line []byte, unit int, fill byte) error {
+	for x := 0; x < len(line); {
+		n, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case n == 128:
+			for ; x < len(line); x++ {
+				line[x] = fill
+			}
+		case n < 128:
+			count := (int(n) + 1) * unit
+			if x+count > len(line) {
+				return fmt.Errorf("raster: run exceeds line width")
+			}
+			if err := readFull(r, line[x:x+unit]); err != nil {
+				return err
+			}
+			for i := unit; i < count; i++ {
+				line[x+i] = line[x+i-unit]
+			}
+			x += count
+		default:
+			count := (257 - int(n)) * unit
+			if x+count > len(line) {
+				return fmt.Errorf("raster: literal run exceeds line width")
+			}
+			if err := readFull(r, line[x:x+count]); err != nil {
+				return err
+			}
+			x += count
+		}
+	}
+	return nil
+}
+
+// readFull 从 ByteReader 读满 buf
+func readFull(r io.ByteReader, buf []byte) error {
+	for i := range buf {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		buf[i] = b
+	}
+	return nil
+}
+
+// readLines 读取整页的压缩行：每行先是行重复字节（本行共出现 n+1 次），随后为压缩的像素
+func readLines(r io.ByteReader, img image.Image, f pixelFormat) error {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	line := make([]byte, f.lineBytes(width))
+	for y := 0; y < height; {
+		repeat, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if err := readLine(r, line, f.unit(), f.fill()); err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		for i := 0; i <= int(repeat) && y < height; i++ {
+			setRow(img, y, line, f)
+			y++
+		}
+	}
+	return nil
+}
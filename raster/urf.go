@@ -0,0 +1,112 @@
+package raster
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// urfMagic URF 文件头的标识
+const urfMagic = "UNIRAST\x00"
+
+// URF 页头中的颜色空间
+const (
+	urfSGray     = 0
+	urfSRGB      = 1
+	urfW         = 4
+	urfDeviceRGB = 5
+)
+
+// URFDecoder 逐页解码 Apple Raster（image/urf）数据流
+type URFDecoder struct {
+	r     *bufio.Reader
+	pages uint32 // 文件头中的页数，0 表示未知
+	read  uint32
+}
+
+// IsURF 判断数据是否以 URF 文件头开始
+func IsURF(header []byte) bool {
+	return len(header) >= len(urfMagic) && string(header[:len(urfMagic)]) == urfMagic
+}
+
+// NewURFDecoder 读取 URF 文件头并创建解码器
+func NewURFDecoder(r io.Reader) (*URFDecoder, error) {
+	br := bufio.NewReader(r)
+	var hdr [12]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return nil, fmt.Errorf("raster: read URF header: %w", err)
+	}
+	if !IsURF(hdr[:]) {
+		return nil, fmt.Errorf("raster: not a URF stream")
+	}
+	return &URFDecoder{r: br, pages: binary.BigEndian.Uint32(hdr[8:12])}, nil
+}
+
+// Pages 返回文件头中声明的页数，0 表示未知
+func (d *URFDecoder) Pages() int {
+	return int(d.pages)
+}
+
+// NextPage 解码下一页，没有更多页面时返回 io.EOF
+//
+// 支持 W8（8 位灰度）与 SRGB24（24 位 RGB）。
+func (d *URFDecoder) NextPage() (*Page, error) {
+	if d.pages > 0 && d.read >= d.pages {
+		return nil, io.EOF
+	}
+
+	var hdr [32]byte
+	if _, err := io.ReadFull(d.r, hdr[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("raster: read URF page header: %w", err)
+	}
+
+	bpp := int(hdr[0])
+	colorSpace := hdr[1]
+	width := int(binary.BigEndian.Uint32(hdr[12:16]))
+	height := int(binary.BigEndian.Uint32(hdr[16:20]))
+	dpi := int(binary.BigEndian.Uint32(hdr[20:24]))
+
+	var format pixelFormat
+	switch {
+	case bpp == 8 && (colorSpace == urfSGray || colorSpace == urfW):
+		format = pixelFormat{model: modelGray, bitsPerPixel: 8}
+	case bpp == 24 && (colorSpace == urfSRGB || colorSpace == urfDeviceRGB):
+		format = pixelFormat{model: modelRGB, bitsPerPixel: 24}
+	default:
+		return nil, fmt.Errorf("raster: unsupported URF page: %d bits per pixel, color space %d", bpp, colorSpace)
+	}
+
+	img, err := newPageImage(width, height, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := readLines(d.r, img, format); err != nil {
+		return nil, fmt.Errorf("raster: URF page %d: %w", d.read+1, err)
+	}
+
+	d.read++
+	return &Page{Image: img, XDPI: dpi, YDPI: dpi}, nil
+}
+
+// DecodeURF 解码 URF 数据流中的全部页面
+func DecodeURF(r io.Reader) ([]*Page, error) {
+	d, err := NewURFDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	var pages []*Page
+	for {
+		page, err := d.NextPage()
+		if err == io.EOF {
+			return pages, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+}
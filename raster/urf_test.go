@@ -0,0 +1,247 @@
+package raster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+)
+
+// encodeLines 以 URF/PWG Raster 共用的方式压缩整页的行：相同的连续行用行重复字节合并，
+// 行内相同的单位用重复控制字节，其余用原样复制，行尾剩余部分全为背景色时用 128 填充
+func encodeLines(rows [][]byte, unit int, fill byte) []byte {
+	var buf bytes.Buffer
+	for y := 0; y < len(rows); {
+		repeat := 1
+		for y+repeat < len(rows) && repeat < 256 && bytes.Equal(rows[y+repeat], rows[y]) {
+			repeat++
+		}
+		buf.WriteByte(byte(repeat - 1))
+		encodeLine(&buf, rows[y], unit, fill)
+		y += repeat
+	}
+	return buf.Bytes()
+}
+
+// encodeLine 压缩一行
+func encodeLine(buf *bytes.Buffer, line []byte, unit int, fill byte) {
+	units := len(line) / unit
+	at := func(i int) []byte { return line[i*unit : (i+1)*unit] }
+	for i := 0; i < units; {
+		if i > 0 && bytes.Count(line[i*unit:], []byte{fill}) == len(line)-i*unit {
+			buf.WriteByte(128)
+			return
+		}
+		run := 1
+		for i+run < units && run < 128 && bytes.Equal(at(i+run), at(i)) {
+			run++
+		}
+		if run > 1 {
+			buf.WriteByte(byte(run - 1))
+			buf.Write(at(i))
+			i += run
+			continue
+		}
+		literal := 1
+		for i+literal < units && literal < 128 &&
+			(i+literal+1 >= units || !bytes.Equal(at(i+literal), at(i+literal+1))) {
+			literal++
+		}
+		buf.WriteByte(byte(257 - literal))
+		buf.Write(line[i*unit : (i+literal)*unit])
+		i += literal
+	}
+}
+
+// testPattern 返回测试页面 (x, y) 处的亮度：前几行相同以产生行重复，
+// 每行包含相同像素的连续段、渐变与白色的行尾
+func testPattern(x, y, width int) uint8 {
+	switch {
+	case y < 5:
+		return uint8(x / 4 * 20)
+	case x < 10:
+		return uint8(y * 3)
+	case x > width-8:
+		return 0xff
+	}
+	return uint8(x*7 + y*13)
+}
+
+// grayRows 返回 8 位灰度页面的像素行
+func grayRows(width, height int) (*image.Gray, [][]byte) {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	rows := make([][]byte, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Pix[y*img.Stride+x] = testPattern(x, y, width)
+		}
+		rows[y] = img.Pix[y*img.Stride : y*img.Stride+width]
+	}
+	return img, rows
+}
+
+// rgbRows 返回 24 位 RGB 页面的像素行
+func rgbRows(width, height int) (*image.RGBA, [][]byte) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	rows := make([][]byte, height)
+	for y := 0; y < height; y++ {
+		row := make([]byte, 0, width*3)
+		for x := 0; x < width; x++ {
+			v := testPattern(x, y, width)
+			c := color.RGBA{R: v, G: v ^ 0x5a, B: 0xff - v, A: 0xff}
+			if v == 0xff {
+				c = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+			}
+			img.SetRGBA(x, y, c)
+			row = append(row, c.R, c.G, c.B)
+		}
+		rows[y] = row
+	}
+	return img, rows
+}
+
+// urfPageHeader 返回 URF 页头
+func urfPageHeader(bpp, colorSpace byte, width, height, dpi int) []byte {
+	hdr := make([]byte, 32)
+	hdr[0], hdr[1] = bpp, colorSpace
+	binary.BigEndian.PutUint32(hdr[12:], uint32(width))
+	binary.BigEndian.PutUint32(hdr[16:], uint32(height))
+	binary.BigEndian.PutUint32(hdr[20:], uint32(dpi))
+	return hdr
+}
+
+// urfStream 返回包含给定页面的 URF 数据流
+func urfStream(pages ...[]byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(urfMagic)
+	binary.Write(&buf, binary.BigEndian, uint32(len(pages)))
+	for _, page := range pages {
+		buf.Write(page)
+	}
+	return buf.Bytes()
+}
+
+// testURFPages 返回 W8 与 SRGB24 两页的 URF 数据流及其原图
+func testURFPages() ([]byte, []image.Image) {
+	gray, grayLines := grayRows(37, 20)
+	rgb, rgbLines := rgbRows(29, 300)
+	w8 := append(urfPageHeader(8, urfW, 37, 20, 300), encodeLines(grayLines, 1, 0xff)...)
+	srgb := append(urfPageHeader(24, urfSRGB, 29, 300, 600), encodeLines(rgbLines, 3, 0xff)...)
+	return urfStream(w8, srgb), []image.Image{gray, rgb}
+}
+
+// comparePages 逐像素比较解码结果与原图
+func comparePages(t *testing.T, got, want image.Image) {
+	t.Helper()
+	if got.Bounds() != want.Bounds() {
+		t.Fatalf("bounds = %v, want %v", got.Bounds(), want.Bounds())
+	}
+	b := want.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if g, w := color.RGBAModel.Convert(got.At(x, y)), color.RGBAModel.Convert(want.At(x, y)); g != w {
+				t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, g, w)
+			}
+		}
+	}
+}
+
+func TestURFRoundTrip(t *testing.T) {
+	data, want := testURFPages()
+	d, err := NewURFDecoder(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Pages() != 2 {
+		t.Errorf("Pages() = %d, want 2", d.Pages())
+	}
+
+	dpis := []int{300, 600}
+	for i, w := range want {
+		page, err := d.NextPage()
+		if err != nil {
+			t.Fatalf("page %d: %v", i+1, err)
+		}
+		if page.XDPI != dpis[i] || page.YDPI != dpis[i] {
+			t.Errorf("page %d: dpi = %dx%d, want %d", i+1, page.XDPI, page.YDPI, dpis[i])
+		}
+		comparePages(t, page.Image, w)
+	}
+	if _, err := d.NextPage(); err != io.EOF {
+		t.Errorf("NextPage after last page = %v, want io.EOF", err)
+	}
+}
+
+func TestURFRepeatedLines(t *testing.T) {
+	// 一个行重复字节覆盖整页，每行只有一个重复控制字节
+	data := urfStream(append(urfPageHeader(8, urfSGray, 100, 256, 72), 255, 99, 0x40))
+	pages, err := DecodeURF(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := pages[0].Image.(*image.Gray)
+	for i, v := range img.Pix {
+		if v != 0x40 {
+			t.Fatalf("pixel %d = %#x, want 0x40", i, v)
+		}
+	}
+}
+
+func TestURFTruncated(t *testing.T) {
+	data, _ := testURFPages()
+	// 文件头之后第一页页头、像素数据与第二页中间截断
+	for _, n := range []int{0, 5, 12 + 10, 12 + 32 + 7, len(data) - 1} {
+		d, err := NewURFDecoder(bytes.NewReader(data[:n]))
+		if err != nil {
+			if n >= 12 {
+				t.Errorf("%d bytes: NewURFDecoder: %v", n, err)
+			}
+			continue
+		}
+		if n < 12 {
+			t.Errorf("%d bytes: NewURFDecoder accepted a truncated file header", n)
+			continue
+		}
+		for err == nil {
+			_, err = d.NextPage()
+		}
+		if err == io.EOF {
+			t.Errorf("%d bytes: truncated stream decoded without error", n)
+		} else if n > 12+32 && !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%d bytes: err = %v, want io.ErrUnexpectedEOF", n, err)
+		}
+	}
+}
+
+func TestURFInvalidHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		hdr  []byte
+		want error
+	}{
+		{"oversized", urfPageHeader(24, urfSRGB, 1<<16, 1<<16, 300), ErrPageTooLarge},
+		// RGB 页面解码为每像素 4 字节
+		{"oversized rgb", urfPageHeader(24, urfSRGB, 4096, 4097, 300), ErrPageTooLarge},
+		{"oversized dimensions", urfPageHeader(8, urfW, 1<<30, 1<<30, 300), ErrPageTooLarge},
+		{"zero width", urfPageHeader(8, urfW, 0, 10, 300), nil},
+		{"unsupported bpp", urfPageHeader(16, urfW, 10, 10, 300), nil},
+		{"unsupported color space", urfPageHeader(24, 2, 10, 10, 300), nil},
+	}
+	for _, tt := range tests {
+		_, err := DecodeURF(bytes.NewReader(urfStream(tt.hdr)))
+		if err == nil {
+			t.Errorf("%s: decoded without error", tt.name)
+			continue
+		}
+		if tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := NewURFDecoder(bytes.NewReader([]byte("RaS2\x00\x00\x00\x00\x00\x00\x00\x00"))); err == nil {
+		t.Error("NewURFDecoder accepted a stream without the URF magic")
+	}
+}
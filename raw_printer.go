@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rawDefaultPort 原始 TCP 打印（JetDirect）的默认端口
+const rawDefaultPort = "9100"
+
+// rawProbeTimeout 查询打印机状态时建立连接的最长等待时间
+const rawProbeTimeout = 2 * time.Second
+
+// RawPrinterManager 通过原始 TCP 端口（9100 / JetDirect）直接发送任务数据的打印机管理器
+//
+// 打印机来自配置文件，不需要在系统中安装驱动或打印队列。
+type RawPrinterManager struct {
+	printers map[string]*rawPrinter
+}
+
+// rawPrinter 一台原始 TCP 打印机及其可复用的连接
+type rawPrinter struct {
+	name        string
+	description string
+	address     string
+	formats     []string
+	color       bool
+	config      RawConfig
+
+	mu   sync.Mutex // 同一时间只发送一个任务
+	conn net.Conn   // 上一个任务结束后保留的连接
+	idle *time.Timer
+}
+
+// NewRawPrinterManager 根据打印机配置创建原始 TCP 打印机管理器
+func NewRawPrinterManager(printers map[string]PrinterConfig) *RawPrinterManager {
+	m := &RawPrinterManager{printers: make(map[string]*rawPrinter)}
+	for name, printer := range printers {
+		m.printers[name] = &rawPrinter{
+			name:        name,
+			description: printer.Description,
+			address:     rawAddress(printer.Address),
+			formats:     passthroughFormats(printer.Formats),
+			color:       printer.Color,
+			config:      printer.Raw.withDefaults(),
+		}
+	}
+	return m
+}
+
+// rawAddress 为没有端口的地址补上默认端口 9100
+func rawAddress(address string) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(address, rawDefaultPort)
+}
+
+// Printers 返回全部打印机名称
+func (m *RawPrinterManager) Printers() []string {
+	names := make([]string, 0, len(m.printers))
+	for name := range m.printers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetPrinters 获取所有打印机，无法连接的打印机状态为 Offline
+func (m *RawPrinterManager) GetPrinters() ([]PrinterInfo, error) {
+	names := m.Printers()
+	status := m.PrinterStatus(names)
+	printers := make([]PrinterInfo, len(names))
+	for i, name := range names {
+		printers[i] = PrinterInfo{
+			Name:        name,
+			Description: m.printers[name].description,
+			Status:      status[name],
+		}
+		if printers[i].Description == "" {
+			printers[i].Description = name
+		}
+	}
+	return printers, nil
+}
+
+// PrinterStatus 只测试指定打印机能否连接，返回 Available 或 Offline，未知的打印机不在结果中
+func (m *RawPrinterManager) PrinterStatus(names []string) map[string]string {
+	status := make(map[string]string)
+
+	// 各打印机的连接测试并行进行
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range names {
+		p, ok := m.printers[name]
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			s := "Available"
+			if err := p.probe(); err != nil {
+				s = "Offline"
+			}
+			mu.Lock()
+			status[name] = s
+			mu.Unlock()
+		}(name)
+	}
+	wg.Wait()
+	return status
+}
+
+// GetCapabilities 返回配置的打印机能力，文档格式只有 application/octet-stream 与配置的格式
+//
+// 任务数据原样发送，打印机不支持的格式不能声明；分辨率与纸张使用默认值。
+func (m *RawPrinterManager) GetCapabilities(name string) (PrinterCapabilities, error) {
+	p, ok := m.printers[name]
+	if !ok {
+		return PrinterCapabilities{}, fmt.Errorf("unknown raw printer %s", name)
+	}
+	return PrinterCapabilities{
+		MakeAndModel: "Raw TCP Printer",
+		Color:        p.color,
+		Formats:      p.formats,
+	}, nil
+}
+
+// passthroughFormats 返回原样发送任务数据的后端支持的文档格式：application/octet-stream 在前，
+// 其后为配置的格式（去掉重复与空值）
+func passthroughFormats(configured []string) []string {
+	formats := []string{formatOctetStream}
+	for _, format := range configured {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format != "" && !containsString(formats, format) {
+			formats = append(formats, format)
+		}
+	}
+	return formats
+}
+
+// GetDefault 原始 TCP 打印机没有默认打印机
+func (m *RawPrinterManager) GetDefault() (string, error) {
+	return "", fmt.Errorf("raw printers have no default printer")
+}
+
+// SetDefault 原始 TCP 打印机不能设为系统默认打印机
+func (m *RawPrinterManager) SetDefault(name string) error {
+	return fmt.Errorf("raw printer %s cannot be the system default printer", name)
+}
+
+// Refresh 断开全部空闲连接，之后的任务重新建立连接
+func (m *RawPrinterManager) Refresh() error {
+	for _, p := range m.printers {
+		p.mu.Lock()
+		p.closeLocked()
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+// SendJob 将文件依次写入打印机连接，copies 大于 1 时重复发送；数据原样发送，不使用 formats
+//
+// 原始 TCP 打印没有任务 ID，返回值始终为空字符串。
+func (m *RawPrinterManager) SendJob(ctx context.Context, name, title string, files, formats []string, template JobTemplate) (string, error) {
+	p, ok := m.printers[name]
+	if !ok {
+		return "", fmt.Errorf("unknown raw printer %s", name)
+	}
+
+	copies := template.Copies
+	if copies < 1 {
+		copies = 1
+	}
+	var sources []string
+	for i := 0; i < copies; i++ {
+		sources = append(sources, files...)
+	}
+
+	return "", p.send(ctx, func(w io.Writer) error {
+		for _, path := range sources {
+			if err := copyFile(w, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// copyFile 将文件内容写入 w
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(w, f); err != nil {
+		return err
+	}
+	return nil
+}
+
+// probe 测试打印机能否连接；已有空闲连接或正在发送任务时视为可连接
+func (p *rawPrinter) probe() error {
+	if !p.mu.TryLock() {
+		return nil
+	}
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		return nil
+	}
+
+	timeout := time.Duration(p.config.ConnectTimeout) * time.Second
+	if timeout > rawProbeTimeout {
+		timeout = rawProbeTimeout
+	}
+	conn, err := net.DialTimeout("tcp", p.address, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// send 通过连接写入一个任务的数据，write 的输出按 ChunkSize 分块并对每块设置写超时
+//
+// 复用的空闲连接可能已被打印机断开，此时若尚未写入任何数据则重新建立连接发送一次。
+func (p *rawPrinter) send(ctx context.Context, write func(w io.Writer) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.idle != nil {
+		p.idle.Stop()
+		p.idle = nil
+	}
+
+	if p.conn != nil && !connAlive(p.conn) {
+		p.closeLocked()
+	}
+	reused := p.conn != nil
+	for {
+		if p.conn == nil {
+			conn, err := p.dial(ctx)
+			if err != nil {
+				return err
+			}
+			p.conn = conn
+		}
+
+		w := &chunkWriter{conn: p.conn, size: p.config.ChunkSize, timeout: time.Duration(p.config.WriteTimeout) * time.Second}
+		err := p.writeJob(ctx, w, write)
+		if err == nil {
+			p.releaseLocked()
+			return nil
+		}
+
+		p.closeLocked()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if isPermanent(err) {
+			// 任务数据无法生成，重新连接也不会成功
+			return err
+		}
+		if reused && w.written == 0 {
+			log.Printf("打印机 %s 的空闲连接已断开，重新连接", p.name)
+			reused = false
+			continue
+		}
+		return fmt.Errorf("failed to send job to %s: %v", p.address, err)
+	}
+}
+
+// connAlive 检查空闲连接是否已被打印机断开
+//
+// 打印机在空闲期间发回的数据（如状态字节）会被丢弃。
+func connAlive(conn net.Conn) bool {
+	if err := conn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		return false
+	}
+	defer conn.SetReadDeadline(time.Time{})
+
+	buf := make([]byte, 512)
+	for {
+		_, err := conn.Read(buf)
+		if err == nil {
+			continue
+		}
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return true
+		}
+		return false
+	}
+}
+
+// dial 建立到打印机的连接
+func (p *rawPrinter) dial(ctx context.Context) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: time.Duration(p.config.ConnectTimeout) * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", p.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", p.address, err)
+	}
+	return conn, nil
+}
+
+// writeJob 写入任务数据，ctx 结束时中断正在进行的写入
+func (p *rawPrinter) writeJob(ctx context.Context, w *chunkWriter, write func(w io.Writer) error) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.conn.SetWriteDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	if err := write(w); err != nil {
+		return err
+	}
+	return w.conn.SetWriteDeadline(time.Time{})
+}
+
+// releaseLocked 任务结束后保留连接等待复用，或按配置立即断开（调用方需持有 mu）
+func (p *rawPrinter) releaseLocked() {
+	if p.config.IdleTimeout <= 0 {
+		p.closeLocked()
+		return
+	}
+
+	conn := p.conn
+	p.idle = time.AfterFunc(time.Duration(p.config.IdleTimeout)*time.Second, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		// 期间连接可能已被新任务使用或替换
+		if p.conn == conn {
+			p.closeLocked()
+		}
+	})
+}
+
+// closeLocked 断开连接（调用方需持有 mu）
+func (p *rawPrinter) closeLocked() {
+	if p.idle != nil {
+		p.idle.Stop()
+		p.idle = nil
+	}
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+// chunkWriter 按固定大小分块写入连接，每块单独设置写超时
+type chunkWriter struct {
+	conn    net.Conn
+	size    int
+	timeout time.Duration
+	written int64
+}
+
+// Write 分块写入 data
+func (w *chunkWriter) Write(data []byte) (int, error) {
+	total := 0
+	for len(data) > 0 {
+		n := len(data)
+		if n > w.size {
+			n = w.size
+		}
+		if err := w.conn.SetWriteDeadline(time.Now().Add(w.timeout)); err != nil {
+			return total, err
+		}
+		written, err := w.conn.Write(data[:n])
+		total += written
+		w.written += int64(written)
+		if err != nil {
+			return total, err
+		}
+		data = data[n:]
+	}
+	return total, nil
+}
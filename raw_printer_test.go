@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rawTestServer 记录收到的连接与数据的原始 TCP 打印机
+type rawTestServer struct {
+	ln   net.Listener
+	mu   sync.Mutex
+	data [][]byte // 每个连接收到的数据
+	done chan int // 连接关闭时发送连接序号
+	// limit 大于 0 时每个连接读满 limit 字节后由打印机断开
+	limit int
+}
+
+// newRawTestServer 在本机随机端口上启动测试打印机
+func newRawTestServer(t *testing.T, limit int) *rawTestServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &rawTestServer{ln: ln, done: make(chan int, 16), limit: limit}
+	t.Cleanup(func() { ln.Close() })
+	go s.serve()
+	return s
+}
+
+// serve 接受连接并读取数据，直到监听关闭
+func (s *rawTestServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		index := len(s.data)
+		s.data = append(s.data, nil)
+		s.mu.Unlock()
+
+		go func() {
+			defer conn.Close()
+			var r io.Reader = conn
+			if s.limit > 0 {
+				r = io.LimitReader(conn, int64(s.limit))
+			}
+			buf := make([]byte, 4096)
+			for {
+				n, err := r.Read(buf)
+				s.mu.Lock()
+				s.data[index] = append(s.data[index], buf[:n]...)
+				s.mu.Unlock()
+				if err != nil {
+					break
+				}
+			}
+			conn.Close()
+			s.done <- index
+		}()
+	}
+}
+
+// wait 等待一个连接关闭，返回该连接收到的数据
+func (s *rawTestServer) wait(t *testing.T) []byte {
+	t.Helper()
+	select {
+	case index := <-s.done:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.data[index]
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the printer connection to close")
+		return nil
+	}
+}
+
+// connections 返回接受的连接数
+func (s *rawTestServer) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.data)
+}
+
+// writeTestFile 在临时目录中写入测试文件
+func writeTestFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// testData 返回 n 字节的测试数据
+func testData(n int, seed byte) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i*7) + seed
+	}
+	return data
+}
+
+// recordingConn 记录每次写入的字节数
+type recordingConn struct {
+	net.Conn
+	writes []int
+}
+
+func (c *recordingConn) Write(p []byte) (int, error) {
+	c.writes = append(c.writes, len(p))
+	return c.Conn.Write(p)
+}
+
+func TestChunkWriterSplitsWrites(t *testing.T) {
+	server := newRawTestServer(t, 0)
+	conn, err := net.Dial("tcp", server.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recordingConn{Conn: conn}
+	w := &chunkWriter{conn: rec, size: 1000, timeout: 5 * time.Second}
+
+	data := testData(4500, 1)
+	if n, err := w.Write(data); err != nil || n != len(data) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	conn.Close()
+
+	want := []int{1000, 1000, 1000, 1000, 500}
+	if len(rec.writes) != len(want) {
+		t.Fatalf("writes = %v, want %v", rec.writes, want)
+	}
+	for i := range want {
+		if rec.writes[i] != want[i] {
+			t.Fatalf("writes = %v, want %v", rec.writes, want)
+		}
+	}
+	if w.written != int64(len(data)) {
+		t.Errorf("written = %d, want %d", w.written, len(data))
+	}
+	if got := server.wait(t); !bytes.Equal(got, data) {
+		t.Errorf("printer received %d bytes, want %d", len(got), len(data))
+	}
+}
+
+func TestRawPrinterReusesConnection(t *testing.T) {
+	server := newRawTestServer(t, 0)
+	m := NewRawPrinterManager(map[string]PrinterConfig{
+		"raw": {Address: server.ln.Addr().String(), Raw: RawConfig{ChunkSize: 512, IdleTimeout: 60}},
+	})
+	first := writeTestFile(t, "first.prn", testData(3000, 1))
+	second := writeTestFile(t, "second.prn", testData(700, 2))
+
+	ctx := context.Background()
+	if _, err := m.SendJob(ctx, "raw", "job", []string{first}, nil, JobTemplate{Copies: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SendJob(ctx, "raw", "job", []string{second}, nil, JobTemplate{}); err != nil {
+		t.Fatal(err)
+	}
+	// 断开空闲连接，测试打印机读到连接结束
+	m.Refresh()
+
+	got := server.wait(t)
+	want := append(append(testData(3000, 1), testData(3000, 1)...), testData(700, 2)...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("printer received %d bytes, want %d", len(got), len(want))
+	}
+	if n := server.connections(); n != 1 {
+		t.Errorf("connections = %d, want 1", n)
+	}
+}
+
+func TestRawPrinterReconnectsAfterPrinterClose(t *testing.T) {
+	// 打印机读完第一个任务后断开空闲连接，第二个任务应重新连接而不是写入已断开的连接
+	server := newRawTestServer(t, 1200)
+	m := NewRawPrinterManager(map[string]PrinterConfig{
+		"raw": {Address: server.ln.Addr().String(), Raw: RawConfig{IdleTimeout: 60}},
+	})
+	first := writeTestFile(t, "first.prn", testData(1200, 1))
+	second := writeTestFile(t, "second.prn", testData(1200, 2))
+
+	ctx := context.Background()
+	if _, err := m.SendJob(ctx, "raw", "job", []string{first}, nil, JobTemplate{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.wait(t); !bytes.Equal(got, testData(1200, 1)) {
+		t.Fatalf("first job: printer received %d bytes", len(got))
+	}
+
+	if _, err := m.SendJob(ctx, "raw", "job", []string{second}, nil, JobTemplate{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.wait(t); !bytes.Equal(got, testData(1200, 2)) {
+		t.Errorf("second job: printer received %d bytes", len(got))
+	}
+	if n := server.connections(); n != 2 {
+		t.Errorf("connections = %d, want 2", n)
+	}
+	m.Refresh()
+}
+
+func TestRawPrinterClosesWithoutIdleTimeout(t *testing.T) {
+	server := newRawTestServer(t, 0)
+	m := NewRawPrinterManager(map[string]PrinterConfig{
+		"raw": {Address: server.ln.Addr().String()},
+	})
+	path := writeTestFile(t, "job.prn", testData(100, 3))
+
+	for i := 0; i < 2; i++ {
+		if _, err := m.SendJob(context.Background(), "raw", "job", []string{path}, nil, JobTemplate{}); err != nil {
+			t.Fatal(err)
+		}
+		if got := server.wait(t); !bytes.Equal(got, testData(100, 3)) {
+			t.Errorf("job %d: printer received %d bytes", i+1, len(got))
+		}
+	}
+	if n := server.connections(); n != 2 {
+		t.Errorf("connections = %d, want 2", n)
+	}
+}
+
+func TestRawPrinterKeepsPermanentError(t *testing.T) {
+	// 复用的连接上任务数据无法生成时，不应当作连接断开而重新连接再试
+	server := newRawTestServer(t, 0)
+	m := NewRawPrinterManager(map[string]PrinterConfig{
+		"raw": {Address: server.ln.Addr().String(), Raw: RawConfig{IdleTimeout: 60}},
+	})
+	path := writeTestFile(t, "job.prn", testData(100, 4))
+	if _, err := m.SendJob(context.Background(), "raw", "job", []string{path}, nil, JobTemplate{}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := m.printers["raw"].send(context.Background(), func(w io.Writer) error {
+		return permanentError(errors.New("unsupported document"))
+	})
+	if !isPermanent(err) {
+		t.Fatalf("send = %v, want a permanent error", err)
+	}
+	server.wait(t)
+	if n := server.connections(); n != 1 {
+		t.Errorf("connections = %d, want 1", n)
+	}
+}
+
+func TestRawPrinterCapabilities(t *testing.T) {
+	m := NewRawPrinterManager(map[string]PrinterConfig{
+		"raw": {Address: "192.0.2.1", Formats: []string{"application/postscript", " Application/VND.HP-PCL ", formatOctetStream}},
+	})
+	caps, err := m.GetCapabilities("raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{formatOctetStream, formatPostScript, formatPCL}
+	if len(caps.Formats) != len(want) {
+		t.Fatalf("formats = %v, want %v", caps.Formats, want)
+	}
+	for i := range want {
+		if caps.Formats[i] != want[i] {
+			t.Fatalf("formats = %v, want %v", caps.Formats, want)
+		}
+	}
+	if caps.Color {
+		t.Error("color reported for a printer not configured as color")
+	}
+	if _, err := m.GetCapabilities("missing"); err == nil {
+		t.Error("GetCapabilities succeeded for an unknown printer")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"airprint-service/ipp"
+)
+
+// retentionInterval 按保留天数清理任务记录的检查间隔
+const retentionInterval = time.Hour
+
+// errUnchanged 回调没有修改任务，JobStore.Update 不会发出通知
+var errUnchanged = errors.New("job unchanged")
+
+// runRetention 在任务结束时释放文档数据，并定期按保留策略清理任务记录、压缩任务历史，直到 ctx 结束
+func (a *AirPrintServer) runRetention(ctx context.Context) {
+	events, unsubscribe := a.jobs.Subscribe()
+	defer unsubscribe()
+
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	a.enforceRetention()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			if event.State.IsTerminal() && !event.Removed {
+				a.enforceRetention()
+			}
+		case <-ticker.C:
+			a.enforceRetention()
+			if err := a.jobs.CompactHistory(); err != nil {
+				log.Printf("压缩任务历史失败: %v", err)
+			}
+		}
+	}
+}
+
+// enforceRetention 执行保留策略
+//
+// 已结束任务的文档数据被删除（除非配置了保留文档）；已结束任务的记录超过保留天数或
+// 保留数量时被清除。未结束的任务不受影响。
+func (a *AirPrintServer) enforceRetention() {
+	retention := a.config.Retention
+	var cutoff time.Time
+	if retention.Days > 0 {
+		cutoff = time.Now().AddDate(0, 0, -retention.Days)
+	}
+
+	// 从最新的任务开始计数，超出保留数量的旧任务被清除
+	jobs := a.jobs.List()
+	finished := 0
+	for i := len(jobs) - 1; i >= 0; i-- {
+		job := jobs[i]
+
+		var terminal bool
+		var completedAt time.Time
+		a.jobs.View(func() {
+			terminal = job.State.IsTerminal()
+			completedAt = job.CompletedAt
+		})
+		if !terminal {
+			continue
+		}
+		finished++
+
+		expired := !cutoff.IsZero() && completedAt.Before(cutoff)
+		overflow := retention.MaxJobs > 0 && finished > retention.MaxJobs
+		if expired || overflow {
+			a.purgeJob(job)
+			continue
+		}
+		if !retention.KeepDocuments {
+			a.releaseDocuments(job)
+		}
+	}
+}
+
+// releaseDocuments 删除已结束任务暂存的文档数据，保留文档的格式与大小等元数据
+func (a *AirPrintServer) releaseDocuments(job *PrintJob) {
+	var paths []string
+	a.jobs.Update(job, func() error {
+		// 检查之后任务可能已被 Restart-Job 重新开始
+		if !job.State.IsTerminal() {
+			return errUnchanged
+		}
+		for _, doc := range job.Documents {
+			if doc.Path != "" {
+				paths = append(paths, doc.Path)
+				doc.Path = ""
+			}
+		}
+		if len(paths) == 0 {
+			return errUnchanged
+		}
+		return nil
+	})
+	for _, path := range paths {
+		a.spool.Remove(path)
+	}
+	if len(paths) > 0 {
+		log.Printf("打印任务 ID: %d 已结束，删除 %d 个暂存文档", job.ID, len(paths))
+	}
+}
+
+// purgeJob 取消未结束的任务，删除其文档并清除任务记录
+func (a *AirPrintServer) purgeJob(job *PrintJob) {
+	var paths []string
+	a.jobs.Update(job, func() error {
+		if !job.State.IsTerminal() {
+			if err := a.jobs.transitionLocked(job, ipp.JobCanceled, "job-canceled-by-operator"); err == nil && job.cancel != nil {
+				job.cancel()
+			}
+		}
+		for _, doc := range job.Documents {
+			if doc.Path != "" {
+				paths = append(paths, doc.Path)
+			}
+		}
+		return nil
+	})
+	a.dequeueJob(job)
+	for _, path := range paths {
+		a.spool.Remove(path)
+	}
+	a.jobs.Remove(job)
+}
+
+// hasDocuments 判断任务的文档数据是否仍然存在（需在 JobStore.View/Update 回调中调用）
+func hasDocuments(job *PrintJob) bool {
+	for _, doc := range job.Documents {
+		if doc.Path == "" {
+			return false
+		}
+	}
+	return len(job.Documents) > 0
+}
+
+// buildPurgeJobsResponse 构建 Purge-Jobs 响应，取消并清除指定打印机的全部任务
+func (a *AirPrintServer) buildPurgeJobsResponse(req *ipp.Message, printer *sharedPrinter) *ipp.Message {
+	user := ""
+	if attr, ok := req.Operation().Get("requesting-user-name"); ok {
+		user, _ = attr.Str()
+	}
+
+	purged := 0
+	for _, job := range a.jobs.List() {
+		var printerName string
+		a.jobs.View(func() {
+			printerName = job.PrinterName
+		})
+		if printerName != printer.Name {
+			continue
+		}
+		a.purgeJob(job)
+		purged++
+	}
+
+	log.Printf("用户 %q 清除了打印机 %s 的 %d 个任务", user, printer.displayName(), purged)
+	return a.newResponse(req, ipp.StatusOK)
+}
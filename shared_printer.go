@@ -0,0 +1,132 @@
+package main
+
+import (
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/grandcat/zeroconf"
+)
+
+// sharedPrinter 通过 AirPrint 共享的打印机，每个本地打印队列对应一个 IPP 资源和一组 mDNS 服务
+type sharedPrinter struct {
+	Name string // 本地打印队列名称，空字符串表示系统默认打印机
+	Info PrinterInfo
+	UUID string
+	Caps PrinterCapabilities
+
+	server          *zeroconf.Server
+	universalServer *zeroconf.Server
+}
+
+// printerResource 返回打印队列对应的 IPP 资源路径
+func printerResource(name string) string {
+	if name == "" {
+		return "/ipp/print"
+	}
+	return "/ipp/print/" + url.PathEscape(name)
+}
+
+// displayName 返回用于 mDNS 服务名与 printer-info 的显示名称
+func (p *sharedPrinter) displayName() string {
+	if p.Info.Description != "" {
+		return p.Info.Description
+	}
+	if p.Name != "" {
+		return p.Name
+	}
+	return "AirPrint Service"
+}
+
+// loadSharedPrinters 为 PrinterManager 返回的每台打印机创建共享打印机
+//
+// 获取不到打印机列表时退回到单个系统默认打印机，与之前的行为一致。
+func (a *AirPrintServer) loadSharedPrinters() {
+	printers, err := a.printerManager.GetPrinters()
+	if err != nil {
+		log.Printf("获取打印机列表失败，仅共享系统默认打印机: %v", err)
+	}
+
+	shared := make(map[string]*sharedPrinter)
+	defaultName := ""
+	for _, info := range printers {
+		shared[info.Name] = &sharedPrinter{
+			Name: info.Name,
+			Info: info,
+			UUID: a.state.PrinterUUID(info.Name),
+			Caps: printerCapabilities(a.printerManager, info.Name),
+		}
+		if info.IsDefault {
+			defaultName = info.Name
+		}
+	}
+
+	if len(shared) == 0 {
+		name, err := a.printerManager.GetDefault()
+		if err != nil {
+			name = ""
+		}
+		shared[name] = &sharedPrinter{
+			Name: name,
+			Info: PrinterInfo{Name: name, Description: name, IsDefault: true},
+			UUID: a.state.PrinterUUID(name),
+			Caps: printerCapabilities(a.printerManager, name),
+		}
+		defaultName = name
+	} else if _, ok := shared[defaultName]; !ok {
+		// 没有默认打印机时以名称最小的打印机作为 /ipp/print 的目标
+		for name := range shared {
+			if defaultName == "" || name < defaultName {
+				defaultName = name
+			}
+		}
+	}
+
+	a.printersMu.Lock()
+	a.printers = shared
+	a.defaultPrinter = defaultName
+	a.printersMu.Unlock()
+}
+
+// printerForPath 根据请求路径（已解码）查找共享打印机
+//
+// "/ipp/print" 与 "/ipp/" 指向默认打印机，"/ipp/print/<队列名>" 指向对应打印机。
+func (a *AirPrintServer) printerForPath(path string) *sharedPrinter {
+	a.printersMu.Lock()
+	defer a.printersMu.Unlock()
+
+	switch path {
+	case "/ipp/print", "/ipp/print/", "/ipp/":
+		return a.printers[a.defaultPrinter]
+	}
+	if !strings.HasPrefix(path, "/ipp/print/") {
+		return nil
+	}
+	name := strings.TrimPrefix(path, "/ipp/print/")
+	// 任务 URI（.../jobs/<id>）同样属于所在的打印机
+	if i := strings.Index(name, "/jobs/"); i >= 0 {
+		name = name[:i]
+	}
+	return a.printers[name]
+}
+
+// sharedPrinterNamed 返回打印队列名称对应的共享打印机，不存在时返回 nil
+func (a *AirPrintServer) sharedPrinterNamed(name string) *sharedPrinter {
+	a.printersMu.Lock()
+	defer a.printersMu.Unlock()
+	return a.printers[name]
+}
+
+// sharedPrinterList 返回按名称排序的全部共享打印机
+func (a *AirPrintServer) sharedPrinterList() []*sharedPrinter {
+	a.printersMu.Lock()
+	defer a.printersMu.Unlock()
+
+	list := make([]*sharedPrinter, 0, len(a.printers))
+	for _, p := range a.printers {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
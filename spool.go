@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// ErrDocumentTooLarge 文档超过允许的最大大小
+var ErrDocumentTooLarge = errors.New("document exceeds maximum job size")
+
+// Spool 文档暂存目录，请求中的文档数据以流的方式写入磁盘
+type Spool struct {
+	dir string
+}
+
+// NewSpool 创建暂存目录
+func NewSpool(dir string) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory %s: %v", dir, err)
+	}
+	return &Spool{dir: dir}, nil
+}
+
+// Write 将 r 中的数据写入新的暂存文件，返回文件路径与大小
+//
+// limit 大于 0 时，数据超过 limit 字节会删除已写入的部分并返回 ErrDocumentTooLarge。
+func (s *Spool) Write(r io.Reader, limit int64, format string) (string, int64, error) {
+	f, err := os.CreateTemp(s.dir, "doc-*"+formatExtension(format))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create spool file: %v", err)
+	}
+	path := f.Name()
+
+	src := r
+	if limit > 0 {
+		// 多读一个字节用于判断是否超出限制
+		src = io.LimitReader(r, limit+1)
+	}
+	size, err := io.Copy(f, src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && limit > 0 && size > limit {
+		err = ErrDocumentTooLarge
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", 0, err
+	}
+	return path, size, nil
+}
+
+// Remove 删除暂存文件
+func (s *Spool) Remove(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("删除暂存文件 %s 失败: %v", path, err)
+	}
+}
+
+// formatExtension 根据文档格式确定文件扩展名
+func formatExtension(format string) string {
+	switch format {
+	case "application/pdf":
+		return ".pdf"
+	case "text/plain":
+		return ".txt"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/urf":
+		return ".urf"
+	case "image/pwg-raster":
+		return ".pwg"
+	case "application/postscript":
+		return ".ps"
+	case "application/vnd.hp-pcl":
+		return ".pcl"
+	case "application/vnd.sato-sbpl":
+		return ".sbpl"
+	case "application/vnd.zebra-zpl":
+		return ".zpl"
+	}
+	return ".dat"
+}
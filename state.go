@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// State 需要跨重启保留的服务状态，如各打印机的 UUID
+type State struct {
+	PrinterUUIDs map[string]string `json:"printer_uuids"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// LoadState 读取状态文件，文件不存在时返回空状态
+//
+// 文件内容无法解析时改名为 <path>.corrupt-<时间> 保留，返回空状态，之后保存的新状态不会覆盖它；
+// 读取失败或无法改名时返回错误，避免用空状态覆盖原有的打印机 UUID。
+func LoadState(path string) (*State, error) {
+	state := &State{
+		PrinterUUIDs: make(map[string]string),
+		path:         path,
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		corrupt := path + ".corrupt-" + time.Now().Format("20060102-150405")
+		if rerr := os.Rename(path, corrupt); rerr != nil {
+			return nil, fmt.Errorf("failed to parse state %s: %v; failed to move it aside: %v", path, err, rerr)
+		}
+		log.Printf("状态文件 %s 无法解析，已改名为 %s 并重新生成: %v", path, corrupt, err)
+		return &State{PrinterUUIDs: make(map[string]string), path: path}, nil
+	}
+	if state.PrinterUUIDs == nil {
+		state.PrinterUUIDs = make(map[string]string)
+	}
+	return state, nil
+}
+
+// PrinterUUID 返回打印机的 UUID，首次出现的打印机生成新的 UUID 并写入状态文件
+func (s *State) PrinterUUID(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if uuid, ok := s.PrinterUUIDs[name]; ok {
+		return uuid
+	}
+
+	uuid, err := newUUID()
+	if err != nil {
+		// 随机数不可用时极为少见，改用由主机名与打印机名称生成的 UUID
+		log.Printf("生成打印机 %s 的随机 UUID 失败，使用基于名称的 UUID: %v", name, err)
+		uuid = nameUUID(name)
+	}
+	s.PrinterUUIDs[name] = uuid
+	if err := s.saveLocked(); err != nil {
+		log.Printf("保存服务状态失败: %v", err)
+	}
+	return uuid
+}
+
+// Save 写入状态文件，用于在启动时确认状态能够保存
+func (s *State) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saveLocked()
+}
+
+// saveLocked 将状态写入临时文件后替换状态文件（调用方需持有 mu）
+func (s *State) saveLocked() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write state %s: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace state %s: %v", s.path, err)
+	}
+	return nil
+}
+
+// newUUID 生成 RFC 4122 第 4 版（随机）UUID
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // 版本 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 变体
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// nameUUID 由主机名与打印机名称生成 RFC 4122 第 5 版（基于名称）UUID
+func nameUUID(name string) string {
+	host, _ := os.Hostname()
+	sum := sha1.Sum([]byte("airprint-service:" + host + ":" + name))
+	b := sum[:16]
+	b[6] = (b[6] & 0x0f) | 0x50 // 版本 5
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 变体
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadStateMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "state.json")
+	state, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.PrinterUUIDs) != 0 {
+		t.Errorf("new state has %d printer UUIDs", len(state.PrinterUUIDs))
+	}
+	uuid := state.PrinterUUID("office")
+
+	again, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := again.PrinterUUIDs["office"]; got != uuid {
+		t.Errorf("reloaded UUID = %q, want %q", got, uuid)
+	}
+}
+
+func TestLoadStateCorruptMovedAside(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.json")
+	corrupt := []byte(`{"printer_uuids": {"office": "0d1e`)
+	if err := os.WriteFile(path, corrupt, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.PrinterUUIDs) != 0 {
+		t.Errorf("state from corrupt file has %d printer UUIDs", len(state.PrinterUUIDs))
+	}
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 损坏的文件改名保留，保存新状态不会覆盖它
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kept string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "state.json.corrupt-") {
+			kept = filepath.Join(dir, e.Name())
+		}
+	}
+	if kept == "" {
+		t.Fatalf("corrupt state not kept, directory has %v", entries)
+	}
+	if data, err := os.ReadFile(kept); err != nil || string(data) != string(corrupt) {
+		t.Errorf("kept state = %q, %v; want %q", data, err, corrupt)
+	}
+}
+
+func TestLoadStateUnreadable(t *testing.T) {
+	// 状态路径是目录，读取失败时不返回可保存的空状态
+	path := t.TempDir()
+	if state, err := LoadState(path); err == nil || state != nil {
+		t.Errorf("LoadState = %v, %v; want an error", state, err)
+	}
+}
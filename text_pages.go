@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"airprint-service/typeset"
+)
+
+// defaultTextMargin 纸张上纯文本的默认页边距（毫米）
+const defaultTextMargin = 10
+
+// textFonts 已加载的字体，按字体文件列表缓存（中文字体文件较大，只读取一次）
+var textFonts = struct {
+	sync.Mutex
+	loaded map[string]typeset.Fonts
+}{loaded: make(map[string]typeset.Fonts)}
+
+// loadTextFonts 读取排版使用的字体，未配置字体文件时使用默认字体
+func loadTextFonts(paths []string) (typeset.Fonts, error) {
+	key := strings.Join(paths, "\x00")
+	textFonts.Lock()
+	defer textFonts.Unlock()
+
+	if fonts, ok := textFonts.loaded[key]; ok {
+		return fonts, nil
+	}
+	fonts := typeset.DefaultFonts()
+	if len(paths) > 0 {
+		var err error
+		if fonts, err = typeset.LoadFonts(paths); err != nil {
+			return nil, fmt.Errorf("failed to load fonts: %v", err)
+		}
+	}
+	textFonts.loaded[key] = fonts
+	return fonts, nil
+}
+
+// textPage 纯文本文档排版使用的页面，尺寸单位为点
+type textPage struct {
+	Width  int
+	Height int
+	DPI    int
+	Margin int
+	Config TextConfig
+}
+
+// newTypesetter 创建按页面设置排版 r 中纯文本的排版器，使用完毕后需调用 Close
+func newTypesetter(r io.Reader, page textPage) (*typeset.Typesetter, error) {
+	fonts, err := loadTextFonts(page.Config.Fonts)
+	if err != nil {
+		return nil, err
+	}
+	return typeset.NewTypesetter(r, fonts, typeset.Options{
+		Width:       page.Width,
+		Height:      page.Height,
+		DPI:         float64(page.DPI),
+		Margin:      page.Margin,
+		FontSize:    page.Config.FontSize,
+		LineSpacing: page.Config.LineSpacing,
+		TabWidth:    page.Config.TabWidth,
+		NoWrap:      page.Config.NoWrap,
+	})
+}
+
+// textPages 将纯文本文件逐页排版并依次交给 fn，文件逐段读取，不会同时保留全部页面
+//
+// 排版失败时返回 PermanentError，fn 返回的错误原样返回。
+func textPages(path string, page textPage, fn func(image.Image) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	t, err := newTypesetter(f, page)
+	if err != nil {
+		return permanentError(fmt.Errorf("failed to render %s: %v", path, err))
+	}
+	defer t.Close()
+	for {
+		img, err := t.NextPage()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return permanentError(fmt.Errorf("failed to render %s: %v", path, err))
+		}
+		if err := fn(img); err != nil {
+			return err
+		}
+	}
+}
+
+// pngHeaderLen PNG 签名与 IHDR 块的字节数，pHYs 块插入在其后
+const pngHeaderLen = 8 + 4 + 4 + 13 + 4
+
+// encodePNG 将页面编码为 PNG，并写入记录分辨率的 pHYs 块，使系统打印队列按原始尺寸打印
+func encodePNG(w io.Writer, img image.Image, dpi int) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+
+	// pHYs：每米的像素数（X、Y）与单位（1 表示米）
+	ppm := uint32(float64(dpi)/0.0254 + 0.5)
+	chunk := make([]byte, 4+4+9+4)
+	binary.BigEndian.PutUint32(chunk[0:], 9)
+	copy(chunk[4:], "pHYs")
+	binary.BigEndian.PutUint32(chunk[8:], ppm)
+	binary.BigEndian.PutUint32(chunk[12:], ppm)
+	chunk[16] = 1
+	binary.BigEndian.PutUint32(chunk[17:], crc32.ChecksumIEEE(chunk[4:17]))
+
+	for _, part := range [][]byte{data[:pngHeaderLen], chunk, data[pngHeaderLen:]} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// systemTextPage 返回系统打印机上纯文本使用的页面：任务或打印机默认的纸张与第一个分辨率
+func (a *AirPrintServer) systemTextPage(printerName string, template JobTemplate) textPage {
+	caps := printerCapabilities(a.printerManager, printerName)
+	width, height, ok := mediaSize(template.Media)
+	if !ok {
+		width, height, ok = mediaSize(caps.DefaultMedia)
+	}
+	if !ok {
+		// A4
+		width, height = 21000, 29700
+	}
+	if template.Orientation == 4 || template.Orientation == 5 {
+		width, height = height, width
+	}
+
+	dpi := caps.Resolutions[0]
+	config := a.config.textConfig(printerName)
+	margin := config.Margin
+	if margin <= 0 {
+		margin = defaultTextMargin
+	}
+	// 纸张尺寸单位为 1/100 毫米
+	return textPage{
+		Width:  width * dpi / 2540,
+		Height: height * dpi / 2540,
+		DPI:    dpi,
+		Margin: int(margin*float64(dpi)/25.4 + 0.5),
+		Config: config,
+	}
+}
+
+// renderTextFiles 将纯文本文件排版为 PNG 页面后交给系统打印队列，使中文等字符按配置的字体打印
+//
+// formats 为各文件记录的文档格式。返回替换后的文件列表、对应的文档格式与删除生成文件的函数，
+// 其他格式的文件保持不变。
+func (a *AirPrintServer) renderTextFiles(filePaths, formats []string, printerName string, template JobTemplate) ([]string, []string, func(), error) {
+	var files, fileFormats, generated []string
+	cleanup := func() {
+		for _, path := range generated {
+			a.spool.Remove(path)
+		}
+	}
+
+	for i, path := range filePaths {
+		if formats[i] != formatText {
+			files = append(files, path)
+			fileFormats = append(fileFormats, formats[i])
+			continue
+		}
+
+		page := a.systemTextPage(printerName, template)
+		pages := 0
+		err := textPages(path, page, func(img image.Image) error {
+			var buf bytes.Buffer
+			if err := encodePNG(&buf, img, page.DPI); err != nil {
+				return fmt.Errorf("failed to encode text page: %v", err)
+			}
+			pagePath, _, err := a.spool.Write(&buf, 0, formatPNG)
+			if err != nil {
+				return err
+			}
+			generated = append(generated, pagePath)
+			files = append(files, pagePath)
+			fileFormats = append(fileFormats, formatPNG)
+			pages++
+			return nil
+		})
+		if err != nil {
+			cleanup()
+			return nil, nil, nil, err
+		}
+		log.Printf("纯文本文档 %s 已排版为 %d 页", path, pages)
+	}
+	return files, fileFormats, cleanup, nil
+}
@@ -0,0 +1,124 @@
+// Package typeset 将纯文本排版为页面图像：按页面宽度自动换行、按页面高度分页，
+// 并按字体顺序回退，使一个文档中的英文与中文等字符都能显示。
+package typeset
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/opentype"
+)
+
+// cjkFontPaths 各系统上常见的中文字体，未配置字体时按顺序查找第一个存在的
+var cjkFontPaths = []string{
+	// Linux
+	"/usr/share/fonts/opentype/noto/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/noto-cjk/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/google-noto-cjk/NotoSansCJK-Regular.ttc",
+	"/usr/share/fonts/truetype/wqy/wqy-microhei.ttc",
+	"/usr/share/fonts/wqy-microhei/wqy-microhei.ttc",
+	"/usr/share/fonts/truetype/droid/DroidSansFallbackFull.ttf",
+	// macOS
+	"/System/Library/Fonts/PingFang.ttc",
+	"/System/Library/Fonts/STHeiti Light.ttc",
+	"/Library/Fonts/Arial Unicode.ttf",
+	// Windows
+	`C:\Windows\Fonts\msyh.ttc`,
+	`C:\Windows\Fonts\simsun.ttc`,
+}
+
+// Fonts 排版使用的字体，字符按顺序使用第一个包含该字符的字体
+type Fonts []*opentype.Font
+
+// LoadFont 读取 TrueType/OpenType 字体文件，字体集（.ttc）取其中第一个字体
+func LoadFont(path string) (*opentype.Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("typeset: %w", err)
+	}
+	collection, err := opentype.ParseCollection(data)
+	if err != nil {
+		return nil, fmt.Errorf("typeset: parse font %s: %w", path, err)
+	}
+	f, err := collection.Font(0)
+	if err != nil {
+		return nil, fmt.Errorf("typeset: parse font %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// LoadFonts 按顺序读取字体文件
+func LoadFonts(paths []string) (Fonts, error) {
+	var fonts Fonts
+	for _, path := range paths {
+		f, err := LoadFont(path)
+		if err != nil {
+			return nil, err
+		}
+		fonts = append(fonts, f)
+	}
+	return fonts, nil
+}
+
+// DefaultFonts 返回内置的 Go Mono 等宽字体，以及系统中找到的第一个中文字体
+func DefaultFonts() Fonts {
+	mono, err := opentype.Parse(gomono.TTF)
+	if err != nil {
+		// 内置字体数据不会解析失败
+		panic(err)
+	}
+	fonts := Fonts{mono}
+	for _, path := range cjkFontPaths {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if f, err := LoadFont(path); err == nil {
+			fonts = append(fonts, f)
+			break
+		}
+	}
+	return fonts
+}
+
+// faces 一组字号与分辨率确定的字形，顺序与 Fonts 相同
+type faces []font.Face
+
+// newFaces 按字号（磅）与分辨率创建各字体的字形
+func (fonts Fonts) newFaces(size, dpi float64) (faces, error) {
+	if len(fonts) == 0 {
+		return nil, fmt.Errorf("typeset: no fonts")
+	}
+	var result faces
+	for _, f := range fonts {
+		face, err := opentype.NewFace(f, &opentype.FaceOptions{
+			Size:    size,
+			DPI:     dpi,
+			Hinting: font.HintingFull,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("typeset: %w", err)
+		}
+		result = append(result, face)
+	}
+	return result, nil
+}
+
+// lookup 返回包含字符 r 的第一个字形及字符宽度，都不包含时使用第一个字形（显示缺字符号）
+func (fs faces) lookup(r rune) (font.Face, int) {
+	for _, face := range fs {
+		if advance, ok := face.GlyphAdvance(r); ok {
+			return face, advance.Round()
+		}
+	}
+	advance, _ := fs[0].GlyphAdvance(r)
+	return fs[0], advance.Round()
+}
+
+// close 释放字形
+func (fs faces) close() {
+	for _, face := range fs {
+		face.Close()
+	}
+}
@@ -0,0 +1,378 @@
+package typeset
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// Options 排版设置，尺寸单位为像素（点）
+type Options struct {
+	Width       int     // 页面宽度
+	Height      int     // 页面高度
+	DPI         float64 // 页面分辨率，用于将字号换算为像素
+	Margin      int     // 四周页边距
+	FontSize    float64 // 字号（磅），0 表示 10
+	LineSpacing float64 // 行距相对字号的倍数，0 表示 1.2
+	TabWidth    int     // 制表位间隔的字符数，0 表示 8
+	NoWrap      bool    // 为 true 时截断超出行宽的部分，否则自动换行
+}
+
+// withDefaults 返回未设置的字段使用默认值后的排版设置
+func (o Options) withDefaults() Options {
+	if o.FontSize <= 0 {
+		o.FontSize = 10
+	}
+	if o.LineSpacing <= 0 {
+		o.LineSpacing = 1.2
+	}
+	if o.TabWidth <= 0 {
+		o.TabWidth = 8
+	}
+	return o
+}
+
+// noBreakBefore 不能出现在行首的标点
+const noBreakBefore = "，。、．！？；：）」』】》〉〕］｝’”…‥・ー々〜,.!?;:)]}%"
+
+// noBreakAfter 不能出现在行尾的标点
+const noBreakAfter = "（「『【《〈〔［｛‘“([{"
+
+// glyph 一行中的一个字符及其位置
+type glyph struct {
+	r    rune
+	face font.Face // 空白字符为 nil
+	x    int
+}
+
+// Typesetter 从 Reader 逐页排版纯文本（白底黑字）
+//
+// 换行符开始新段落，换页符（\f）开始新页面；英文等按单词换行，中日韩文字可以在任意
+// 两个字之间换行，但避免标点出现在行首或行尾。单个单词超过行宽时在行宽处断开。
+// 文本逐字读取，只保留当前段落与尚未输出的页面的排版结果，页面在 NextPage 时才绘制。
+// 开头的字节顺序标记与末尾的空行、换页被忽略，连续的无效 UTF-8 字节显示为一个 U+FFFD。
+type Typesetter struct {
+	r          *bufio.Reader
+	opt        Options
+	faces      faces
+	layout     *layout
+	perPage    int // 每页行数
+	lineHeight int
+	ascent     int
+	top        int // 第一行的上边缘
+
+	started   bool        // 已读取第一个字符
+	invalid   bool        // 上一个字符为无效的 UTF-8 字节
+	content   bool        // 已读到换行与换页以外的字符
+	paragraph []rune      // 当前段落
+	pending   []rune      // 尚未确定是否位于文本末尾的换行与换页
+	lines     [][]glyph   // 当前页已排好的行
+	ready     [][][]glyph // 已排满、等待绘制的页面
+	eof       bool
+}
+
+// NewTypesetter 创建从 r 读取文本的排版器，页面或字号不合理时返回错误
+//
+// 使用完毕后需调用 Close 释放字形。
+func NewTypesetter(r io.Reader, fonts Fonts, opt Options) (*Typesetter, error) {
+	opt = opt.withDefaults()
+	if opt.Width <= 0 || opt.Height <= 0 || opt.DPI <= 0 {
+		return nil, fmt.Errorf("typeset: invalid page %dx%d at %v dpi", opt.Width, opt.Height, opt.DPI)
+	}
+	lineWidth := opt.Width - 2*opt.Margin
+	lineHeight := int(math.Round(opt.FontSize * opt.DPI / 72 * opt.LineSpacing))
+	if lineWidth <= 0 || lineHeight <= 0 || lineHeight > opt.Height-2*opt.Margin {
+		return nil, fmt.Errorf("typeset: margin %d or font size %v too large for page %dx%d",
+			opt.Margin, opt.FontSize, opt.Width, opt.Height)
+	}
+
+	fs, err := fonts.newFaces(opt.FontSize, opt.DPI)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &layout{faces: fs, width: lineWidth, noWrap: opt.NoWrap}
+	_, space := fs.lookup(' ')
+	l.tab = space * opt.TabWidth
+	if l.tab <= 0 {
+		l.tab = 1
+	}
+
+	ascent := 0
+	for _, face := range fs {
+		if a := face.Metrics().Ascent.Ceil(); a > ascent {
+			ascent = a
+		}
+	}
+	return &Typesetter{
+		r:          bufio.NewReader(r),
+		opt:        opt,
+		faces:      fs,
+		layout:     l,
+		perPage:    (opt.Height - 2*opt.Margin) / lineHeight,
+		lineHeight: lineHeight,
+		ascent:     ascent,
+		// 字符在行高中垂直居中
+		top: opt.Margin + (lineHeight-int(math.Round(opt.FontSize*opt.DPI/72)))/2,
+	}, nil
+}
+
+// NextPage 排版并绘制下一页，没有更多页面时返回 io.EOF；文本为空时没有页面
+func (t *Typesetter) NextPage() (*image.Gray, error) {
+	for len(t.ready) == 0 {
+		if t.eof {
+			return nil, io.EOF
+		}
+		if err := t.read(); err != nil {
+			return nil, err
+		}
+	}
+	lines := t.ready[0]
+	t.ready[0] = nil
+	t.ready = t.ready[1:]
+	return t.draw(lines), nil
+}
+
+// Close 释放字形
+func (t *Typesetter) Close() {
+	t.faces.close()
+}
+
+// read 读取一个字符
+//
+// 换行与换页先记下，读到之后的文字时才结束段落或页面，使文本末尾的空行与换页不产生
+// 多余的行和页面；换行之后的换页只结束页面。\r\n 与单独的 \r 视为换行。
+func (t *Typesetter) read() error {
+	r, size, err := t.r.ReadRune()
+	if err == io.EOF {
+		t.eof = true
+		if t.content {
+			t.endParagraph()
+			t.endPage()
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("typeset: %w", err)
+	}
+
+	first := !t.started
+	t.started = true
+	// 连续的无效字节只显示一个替换字符
+	invalid := r == utf8.RuneError && size == 1
+	if invalid && t.invalid {
+		return nil
+	}
+	t.invalid = invalid
+
+	switch {
+	case r == '\ufeff' && first:
+		return nil
+	case r == '\r':
+		if next, _, err := t.r.ReadRune(); err == nil && next != '\n' {
+			t.r.UnreadRune()
+		}
+		r = '\n'
+	}
+	if r == '\n' || r == '\f' {
+		t.pending = append(t.pending, r)
+		return nil
+	}
+
+	ended := false // 段落已由前一个换行结束，紧随其后的换页不再产生空行
+	for _, br := range t.pending {
+		if br == '\n' || !ended {
+			t.endParagraph()
+		}
+		if br == '\f' {
+			t.endPage()
+		}
+		ended = true
+	}
+	t.pending = t.pending[:0]
+	t.content = true
+	t.paragraph = append(t.paragraph, r)
+	return nil
+}
+
+// endParagraph 将当前段落排成行加入当前页，页面排满时转入等待绘制的页面
+func (t *Typesetter) endParagraph() {
+	for _, line := range t.layout.wrap(t.paragraph) {
+		if len(t.lines) == t.perPage {
+			t.ready = append(t.ready, t.lines)
+			t.lines = nil
+		}
+		t.lines = append(t.lines, line)
+	}
+	t.paragraph = t.paragraph[:0]
+}
+
+// endPage 结束当前页
+func (t *Typesetter) endPage() {
+	t.ready = append(t.ready, t.lines)
+	t.lines = nil
+}
+
+// draw 绘制一页
+func (t *Typesetter) draw(lines [][]glyph) *image.Gray {
+	opt := t.opt
+	img := image.NewGray(image.Rect(0, 0, opt.Width, opt.Height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	for n, line := range lines {
+		baseline := t.top + n*t.lineHeight + t.ascent
+		for _, g := range line {
+			if g.face == nil {
+				continue
+			}
+			d := font.Drawer{
+				Dst:  img,
+				Src:  image.Black,
+				Face: g.face,
+				Dot:  fixed.P(opt.Margin+g.x, baseline),
+			}
+			d.DrawString(string(g.r))
+		}
+	}
+	return img
+}
+
+// Render 将文本排版为全部页面图像，文本为空时不返回页面
+//
+// 排版规则见 Typesetter；页面较多时应使用 Typesetter 逐页处理。
+func Render(text string, fonts Fonts, opt Options) ([]*image.Gray, error) {
+	t, err := NewTypesetter(strings.NewReader(text), fonts, opt)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+
+	var pages []*image.Gray
+	for {
+		page, err := t.NextPage()
+		if err == io.EOF {
+			return pages, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, page)
+	}
+}
+
+// layout 一段文字的换行状态
+type layout struct {
+	faces  faces
+	width  int
+	tab    int // 制表位间隔（像素）
+	noWrap bool
+}
+
+// wrap 将一个段落排成若干行，空段落为一个空行
+func (l *layout) wrap(paragraph []rune) [][]glyph {
+	var lines [][]glyph
+	var line []glyph
+	x := 0
+	wrapped := false // 自动换行后的新行忽略行首空白
+
+	newLine := func() {
+		lines = append(lines, line)
+		line, x, wrapped = nil, 0, true
+	}
+
+	for _, segment := range segments(paragraph) {
+		if unicode.IsSpace(segment[0]) {
+			if wrapped && len(line) == 0 {
+				continue
+			}
+			w := l.spaceWidth(segment[0], x)
+			if x+w > l.width {
+				if !l.noWrap {
+					newLine()
+				}
+				continue
+			}
+			line = append(line, glyph{r: segment[0], x: x})
+			x += w
+			continue
+		}
+
+		glyphs, w := l.measure(segment)
+		if x+w > l.width && len(line) > 0 && !l.noWrap {
+			newLine()
+		}
+		for _, g := range glyphs {
+			_, gw := l.faces.lookup(g.r)
+			if x+gw > l.width && len(line) > 0 {
+				if l.noWrap {
+					break
+				}
+				// 单词超过行宽，在行宽处断开
+				newLine()
+			}
+			g.x = x
+			line = append(line, g)
+			x += gw
+		}
+	}
+	return append(lines, line)
+}
+
+// spaceWidth 返回位于 x 处的空白字符的宽度，制表符延伸到下一个制表位
+func (l *layout) spaceWidth(r rune, x int) int {
+	if r == '\t' {
+		return (x/l.tab+1)*l.tab - x
+	}
+	_, w := l.faces.lookup(r)
+	return w
+}
+
+// measure 返回不可断开的一段文字的字符与总宽度
+func (l *layout) measure(segment []rune) ([]glyph, int) {
+	glyphs := make([]glyph, len(segment))
+	width := 0
+	for i, r := range segment {
+		face, w := l.faces.lookup(r)
+		glyphs[i] = glyph{r: r, face: face}
+		width += w
+	}
+	return glyphs, width
+}
+
+// segments 将段落切分为换行时不可断开的片段，每个空白字符单独成为一段
+func segments(paragraph []rune) [][]rune {
+	var result [][]rune
+	start := 0
+	for i := 1; i <= len(paragraph); i++ {
+		if i == len(paragraph) || canBreak(paragraph[i-1], paragraph[i]) {
+			result = append(result, paragraph[start:i])
+			start = i
+		}
+	}
+	return result
+}
+
+// canBreak 判断两个字符之间能否换行
+func canBreak(prev, next rune) bool {
+	if unicode.IsSpace(prev) || unicode.IsSpace(next) {
+		return true
+	}
+	if strings.ContainsRune(noBreakBefore, next) || strings.ContainsRune(noBreakAfter, prev) {
+		return false
+	}
+	return isCJK(prev) || isCJK(next)
+}
+
+// isCJK 判断是否为可以在任意位置换行的中日韩文字或全角符号
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x3000 && r <= 0x303f) || (r >= 0xff00 && r <= 0xffef)
+}
@@ -0,0 +1,314 @@
+package typeset
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/opentype"
+)
+
+// testFonts 只包含内置 Go Mono 字体，结果不受系统中安装的字体影响
+func testFonts(t *testing.T) Fonts {
+	t.Helper()
+	mono, err := opentype.Parse(gomono.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Fonts{mono}
+}
+
+// testOptions 返回 72 dpi、10 磅（行高 12 像素）、无页边距，每行 columns 个字符宽、每页 rows 行的排版设置
+func testOptions(t *testing.T, columns, rows int) Options {
+	t.Helper()
+	fs, err := testFonts(t).newFaces(10, 72)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.close()
+	_, w := fs.lookup('x')
+	return Options{Width: columns * w, Height: rows * 12, DPI: 72, FontSize: 10}
+}
+
+// lineText 返回一行的文字，去掉行尾空白
+func lineText(line []glyph) string {
+	var b strings.Builder
+	for _, g := range line {
+		b.WriteRune(g.r)
+	}
+	return strings.TrimRight(b.String(), " \t")
+}
+
+// layoutPages 排版 text 并返回各页各行的文字，不绘制页面
+func layoutPages(t *testing.T, text string, opt Options) [][]string {
+	t.Helper()
+	ts, err := NewTypesetter(strings.NewReader(text), testFonts(t), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	for !ts.eof {
+		if err := ts.read(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var pages [][]string
+	for _, page := range ts.ready {
+		lines := []string{}
+		for _, line := range page {
+			lines = append(lines, lineText(line))
+		}
+		pages = append(pages, lines)
+	}
+	return pages
+}
+
+// wrapLines 按 columns 个字符的行宽排版一个段落
+func wrapLines(t *testing.T, paragraph string, columns int, noWrap bool) []string {
+	t.Helper()
+	opt := testOptions(t, columns, 10)
+	opt.NoWrap = noWrap
+	ts, err := NewTypesetter(strings.NewReader(""), testFonts(t), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	var lines []string
+	for _, line := range ts.layout.wrap([]rune(paragraph)) {
+		lines = append(lines, lineText(line))
+	}
+	return lines
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name      string
+		paragraph string
+		columns   int
+		noWrap    bool
+		want      []string
+	}{
+		{"fits", "hello world", 20, false, []string{"hello world"}},
+		{"word wrap", "the quick brown fox", 10, false, []string{"the quick", "brown fox"}},
+		{"leading space dropped after wrap", "aaaa bbbb", 4, false, []string{"aaaa", "bbbb"}},
+		{"over-wide word", "abcdefghij", 4, false, []string{"abcd", "efgh", "ij"}},
+		{"over-wide word after text", "ab cdefghij", 4, false, []string{"ab", "cdef", "ghij"}},
+		{"empty paragraph", "", 10, false, []string{""}},
+		{"cjk between any characters", "中文排版测试", 4, false, []string{"中文排版", "测试"}},
+		{"cjk mixed with words", "打印 label 标签", 7, false, []string{"打印", "label 标", "签"}},
+		{"no break before punctuation", "中文，排版", 2, false, []string{"中", "文，", "排版"}},
+		{"no break after punctuation", "中文「排版」", 3, false, []string{"中文", "「排", "版」"}},
+		{"ascii punctuation stays with word", "ab, cd", 3, false, []string{"ab,", "cd"}},
+		{"no wrap truncates", "abcdefghij klm", 4, true, []string{"abcd"}},
+		{"no wrap keeps words that fit", "ab cd ef", 5, true, []string{"ab cd"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrapLines(t, tt.paragraph, tt.columns, tt.noWrap); !equalStrings(got, tt.want) {
+				t.Errorf("wrap(%q) = %q, want %q", tt.paragraph, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		paragraph string
+		want      []string
+	}{
+		{"ab cd", []string{"ab", " ", "cd"}},
+		{"a\t b", []string{"a", "\t", " ", "b"}},
+		{"中文。", []string{"中", "文。"}},
+		{"（中）", []string{"（中）"}},
+		{"abc中", []string{"abc", "中"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, segment := range segments([]rune(tt.paragraph)) {
+			got = append(got, string(segment))
+		}
+		if !equalStrings(got, tt.want) {
+			t.Errorf("segments(%q) = %q, want %q", tt.paragraph, got, tt.want)
+		}
+	}
+}
+
+func TestCanBreak(t *testing.T) {
+	tests := []struct {
+		prev, next rune
+		want       bool
+	}{
+		{'a', 'b', false},
+		{'a', ' ', true},
+		{' ', 'a', true},
+		{'中', '文', true},
+		{'a', '中', true},
+		{'中', '。', false},
+		{'中', '）', false},
+		{'（', '中', false},
+		{'“', 'a', false},
+		{'。', '中', true},
+		{'a', ',', false},
+	}
+	for _, tt := range tests {
+		if got := canBreak(tt.prev, tt.next); got != tt.want {
+			t.Errorf("canBreak(%q, %q) = %v, want %v", tt.prev, tt.next, got, tt.want)
+		}
+	}
+}
+
+func TestTabStops(t *testing.T) {
+	opt := testOptions(t, 40, 10)
+	opt.TabWidth = 4
+	ts, err := NewTypesetter(strings.NewReader(""), testFonts(t), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	_, w := ts.faces.lookup('x')
+
+	lines := ts.layout.wrap([]rune("a\tbcdef\tg"))
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1", len(lines))
+	}
+	want := map[rune]int{'a': 0, 'b': 4 * w, 'g': 12 * w}
+	for _, g := range lines[0] {
+		if x, ok := want[g.r]; ok && g.x != x {
+			t.Errorf("%q at x = %d, want %d", g.r, g.x, x)
+		}
+	}
+}
+
+func TestTabWrapsAtLineEnd(t *testing.T) {
+	// 到下一个制表位超出行宽时换行，新行开头不保留制表符
+	if got, want := wrapLines(t, "abcdefghi\tj", 10, false), []string{"abcdefghi", "j"}; !equalStrings(got, want) {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want [][]string
+	}{
+		{"empty", "", nil},
+		{"only line breaks", "\n\n\f\n", nil},
+		{"crlf and cr", "one\r\ntwo\rthree\n", [][]string{{"one", "two", "three"}}},
+		{"blank lines kept", "a\n\nb", [][]string{{"a", "", "b"}}},
+		{"leading blank line kept", "\na", [][]string{{"", "a"}}},
+		{"bom dropped", "\ufeffhello", [][]string{{"hello"}}},
+		{"bom inside text kept", "a\ufeffb", [][]string{{"a\ufeffb"}}},
+		{"invalid utf-8 collapsed", "a\xff\xfe\xfdb", [][]string{{"a\ufffdb"}}},
+		{"separate invalid bytes", "\xffa\xff", [][]string{{"\ufffda\ufffd"}}},
+		{"form feed", "page1\fpage2", [][]string{{"page1"}, {"page2"}}},
+		{"form feed after line break", "page1\n\fpage2", [][]string{{"page1"}, {"page2"}}},
+		{"blank line before form feed", "a\n\n\fb", [][]string{{"a", ""}, {"b"}}},
+		{"consecutive form feeds", "a\f\fb", [][]string{{"a"}, {}, {"b"}}},
+		{"line break after form feed", "a\f\nb", [][]string{{"a"}, {"", "b"}}},
+		{"trailing blank lines and pages dropped", "text\n\n\n\f\n\f", [][]string{{"text"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := layoutPages(t, tt.text, testOptions(t, 20, 10))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d pages %q, want %d pages %q", len(got), got, len(tt.want), tt.want)
+			}
+			for i := range got {
+				if !equalStrings(got[i], tt.want[i]) {
+					t.Errorf("page %d = %q, want %q", i+1, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPagination(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		lines []int // 各页的行数
+	}{
+		{"exactly one page", "1\n2\n3", []int{3}},
+		{"overflow", "1\n2\n3\n4\n5\n6\n7", []int{3, 3, 1}},
+		{"wrapped lines count", "aaaaaaaaaaaaaaaaaaaaaaaaa\nb", []int{3, 1}},
+		{"form feed on full page", "1\n2\n3\f4", []int{3, 1}},
+		{"line break and form feed on full page", "1\n2\n3\n\f4", []int{3, 1}},
+		{"trailing line break on full page", "1\n2\n3\n", []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := layoutPages(t, tt.text, testOptions(t, 10, 3))
+			var got []int
+			for _, page := range pages {
+				got = append(got, len(page))
+			}
+			if len(got) != len(tt.lines) {
+				t.Fatalf("page lines = %v, want %v", got, tt.lines)
+			}
+			for i := range got {
+				if got[i] != tt.lines[i] {
+					t.Fatalf("page lines = %v, want %v", got, tt.lines)
+				}
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	opt := testOptions(t, 20, 5)
+	opt.Margin = 2
+	opt.Width += 4
+	opt.Height += 4
+
+	pages, err := Render("first\fsecond\n", testFonts(t), opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("got %d pages, want 2", len(pages))
+	}
+	for i, page := range pages {
+		if b := page.Bounds(); b.Dx() != opt.Width || b.Dy() != opt.Height {
+			t.Errorf("page %d is %dx%d, want %dx%d", i+1, b.Dx(), b.Dy(), opt.Width, opt.Height)
+		}
+		black := 0
+		for _, v := range page.Pix {
+			if v < 128 {
+				black++
+			}
+		}
+		if black == 0 {
+			t.Errorf("page %d is blank", i+1)
+		}
+	}
+
+	if pages, err := Render("\n\n", testFonts(t), opt); err != nil || len(pages) != 0 {
+		t.Errorf("Render of blank text = %d pages, %v; want none", len(pages), err)
+	}
+}
+
+func TestNewTypesetterRejectsTinyPage(t *testing.T) {
+	opt := Options{Width: 100, Height: 10, DPI: 72, FontSize: 10}
+	if _, err := NewTypesetter(strings.NewReader("x"), testFonts(t), opt); err == nil {
+		t.Error("NewTypesetter accepted a page shorter than one line")
+	}
+	opt = Options{Width: 100, Height: 100, DPI: 72, Margin: 50}
+	if _, err := NewTypesetter(strings.NewReader("x"), testFonts(t), opt); err == nil {
+		t.Error("NewTypesetter accepted margins wider than the page")
+	}
+}